@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go-boilerplate/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Handlers struct {
+	logger        logging.Logger
+	auditServices Services
+}
+
+func NewInstanceOfAuditHandlers(logger logging.Logger, auditServices Services) *Handlers {
+	return &Handlers{logger, auditServices}
+}
+
+// ParseListEventsQuery reads the paging and filter query params. Types are comma separated and from/to are
+// RFC 3339 timestamps.
+func ParseListEventsQuery(c *gin.Context) (ListEventsQuery, error) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil {
+		return ListEventsQuery{}, err
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "25"))
+	if err != nil {
+		return ListEventsQuery{}, err
+	}
+
+	query := ListEventsQuery{
+		Page:  page,
+		Limit: limit,
+		Email: strings.ToLower(strings.Trim(c.DefaultQuery("email", ""), " ")),
+		Actor: strings.ToLower(strings.Trim(c.DefaultQuery("actor", ""), " ")),
+	}
+	if types := c.DefaultQuery("type", ""); types != "" {
+		query.Types = strings.Split(types, ",")
+	}
+	if from := c.DefaultQuery("from", ""); from != "" {
+		query.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return ListEventsQuery{}, err
+		}
+	}
+	if to := c.DefaultQuery("to", ""); to != "" {
+		query.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return ListEventsQuery{}, err
+		}
+	}
+	return query, nil
+}
+
+// List is the admin query API. It can search across all accounts.
+func (h *Handlers) List(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Audit")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "List")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+
+	h.logger.Info(ctx, "Called")
+
+	query, err := ParseListEventsQuery(c)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+	if err := query.Valid(); err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+
+	events, total, err := h.auditServices.List(ctx, query)
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Error: Internal server error"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Events retrieved", "events": events, "page": query.Page, "limit": query.Limit, "total": total})
+	return
+}
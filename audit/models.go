@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Event types. These are stored so they should not be renamed.
+const (
+	SignInSucceeded = "signIn.succeeded"
+	SignInFailed    = "signIn.failed"
+	SessionLocked   = "session.locked"
+	SessionUnlocked = "session.unlocked"
+	PasswordReset   = "password.reset"
+	PasswordChanged = "password.changed"
+	AccountLocked   = "account.locked"
+	CarCreated      = "car.created"
+	CarUpdated      = "car.updated"
+	CarDeleted      = "car.deleted"
+	CarStatusChanged = "car.statusChanged"
+	CarsExported     = "car.exported"
+	CarRestored      = "car.restored"
+	CarPurged        = "car.purged"
+
+	CarTransferRequested = "car.transferRequested"
+	CarTransferAccepted  = "car.transferAccepted"
+	CarTransferDeclined  = "car.transferDeclined"
+	CarTransferCancelled = "car.transferCancelled"
+
+	OrganizationCreated     = "organization.created"
+	OrganizationInvited     = "organization.memberInvited"
+	OrganizationJoined      = "organization.memberJoined"
+	OrganizationRoleChanged = "organization.memberRoleChanged"
+	OrganizationRemoved     = "organization.memberRemoved"
+)
+
+// Event is a single thing that happened to an account. Events are only ever inserted, never updated or deleted.
+type Event struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Type      string             `json:"type" bson:"type"`
+	Email     string             `json:"email" bson:"email"`                           // The account the event belongs to
+	Actor     string             `json:"actor" bson:"actor"`                           // Who caused the event, usually the same as email
+	Resource  string             `json:"resource,omitempty" bson:"resource,omitempty"` // ID of the object acted on (Ex. car ID)
+	IP        string             `json:"ip" bson:"ip"`
+	UserAgent string             `json:"userAgent" bson:"userAgent"`
+	RequestID string             `json:"requestId" bson:"requestId"`
+	Metadata  map[string]string  `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	Created   time.Time          `json:"created" bson:"created"`
+}
+
+type ListEventsQuery struct {
+	Page  int       `json:"page"`
+	Limit int       `json:"limit"`
+	Email string    `json:"email"`
+	Actor string    `json:"actor"`
+	Types []string  `json:"types"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+}
+
+func (q *ListEventsQuery) Valid() error {
+	if q.Page < 1 {
+		return errors.New("Error: Page must be at least 1")
+	}
+	if q.Limit < 1 || q.Limit > 100 {
+		return errors.New("Error: Limit must be between 1 and 100")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return errors.New("Error: From must be before to")
+	}
+	return nil
+}
+
+func (q *ListEventsQuery) Filter() bson.M {
+	filter := bson.M{}
+	if q.Email != "" {
+		filter["email"] = q.Email
+	}
+	if q.Actor != "" {
+		filter["actor"] = q.Actor
+	}
+	if len(q.Types) > 0 {
+		filter["type"] = bson.M{"$in": q.Types}
+	}
+	created := bson.M{}
+	if !q.From.IsZero() {
+		created["$gte"] = q.From
+	}
+	if !q.To.IsZero() {
+		created["$lte"] = q.To
+	}
+	if len(created) > 0 {
+		filter["created"] = created
+	}
+	return filter
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository is append only, there is intentionally no update or delete.
+type Repository struct {
+	db             *mongo.Database
+	collectionName string
+}
+
+func NewInstanceOfAuditRepository(db *mongo.Database) Repository {
+	return Repository{db: db, collectionName: "auditEvents"}
+}
+
+func (r *Repository) Save(event Event) error {
+	_, err := r.db.Collection(r.collectionName).InsertOne(context.TODO(), event)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) List(query ListEventsQuery) ([]Event, int64, error) {
+	filter := query.Filter()
+
+	total, err := r.db.Collection(r.collectionName).CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return []Event{}, 0, err
+	}
+
+	options := options.Find()
+	options.SetLimit(int64(query.Limit))
+	options.SetSkip(int64((query.Page * query.Limit) - query.Limit))
+	options.SetSort(bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}})
+
+	cursor, err := r.db.Collection(r.collectionName).Find(context.TODO(), filter, options)
+	if err != nil {
+		return []Event{}, 0, err
+	}
+
+	events := []Event{}
+	if err := cursor.All(context.TODO(), &events); err != nil {
+		return []Event{}, 0, err
+	}
+	return events, total, nil
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"go-boilerplate/logging"
+	"time"
+)
+
+type Services struct {
+	logger          logging.Logger
+	auditRepository Repository
+}
+
+func NewInstanceOfAuditServices(logger logging.Logger, auditRepository Repository) Services {
+	return Services{logger, auditRepository}
+}
+
+// Record saves the event. The request ID, client IP and user agent are pulled from the context. A failure is
+// logged instead of returned so a problem with the audit log never blocks the user.
+func (s *Services) Record(ctx context.Context, event Event) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "Record"))
+
+	if event.Actor == "" {
+		event.Actor = event.Email
+	}
+	if value, ok := ctx.Value(logging.CtxRequestID).(string); ok {
+		event.RequestID = value
+	}
+	if value, ok := ctx.Value(logging.CtxClientIP).(string); ok {
+		event.IP = value
+	}
+	if value, ok := ctx.Value(logging.CtxUserAgent).(string); ok {
+		event.UserAgent = value
+	}
+	event.Created = time.Now()
+
+	err := s.auditRepository.Save(event)
+	if err != nil {
+		s.logger.Error(ctx, "failed to save audit event "+event.Type, err)
+	}
+}
+
+func (s *Services) List(ctx context.Context, query ListEventsQuery) ([]Event, int64, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "List")
+
+	events, total, err := s.auditRepository.List(query)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to list audit events", err)
+		return []Event{}, 0, err
+	}
+	return events, total, nil
+}
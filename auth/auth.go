@@ -7,7 +7,18 @@ import (
 	"strings"
 )
 
+// ValidateAuth requires a valid, unlocked session that is not waiting on a password change.
 func ValidateAuth(userRepository user.Repository) gin.HandlerFunc {
+	return validateAuth(userRepository, false)
+}
+
+// ValidateAuthAllowPasswordReset is the same as ValidateAuth but also lets through sessions that were
+// created with an expired password. Only use it on the routes that let the user change their password.
+func ValidateAuthAllowPasswordReset(userRepository user.Repository) gin.HandlerFunc {
+	return validateAuth(userRepository, true)
+}
+
+func validateAuth(userRepository user.Repository, allowPasswordReset bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authToken := c.Request.Header.Get("Authorization")
 		if authToken == "" {
@@ -33,8 +44,38 @@ func ValidateAuth(userRepository user.Repository) gin.HandlerFunc {
 			c.AbortWithStatus(403)
 			return
 		}
+		if session.PasswordResetRequired && !allowPasswordReset {
+			fmt.Println("Session requires password reset")
+			c.AbortWithStatus(403)
+			return
+		}
 		c.Set("session", session)
 
 		c.Next()
 	}
 }
+
+// ValidateAdmin must be used after ValidateAuth. It only allows sessions for emails listed in ADMIN_EMAILS.
+func ValidateAdmin() gin.HandlerFunc {
+	adminEmails := GetAdminEmails()
+	return func(c *gin.Context) {
+		i, exists := c.Get("session")
+		if !exists {
+			c.AbortWithStatus(403)
+			return
+		}
+		session, ok := i.(user.Session)
+		if !ok {
+			c.AbortWithStatus(403)
+			return
+		}
+		for _, email := range adminEmails {
+			if session.Email == email {
+				c.Next()
+				return
+			}
+		}
+		fmt.Println("Not an admin")
+		c.AbortWithStatus(403)
+	}
+}
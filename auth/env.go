@@ -0,0 +1,18 @@
+package auth
+
+import (
+	"os"
+	"strings"
+)
+
+// GetAdminEmails returns the comma separated list of emails allowed to use the admin APIs.
+func GetAdminEmails() []string {
+	adminEmails := []string{}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		email = strings.ToLower(strings.Trim(email, " "))
+		if email != "" {
+			adminEmails = append(adminEmails, email)
+		}
+	}
+	return adminEmails
+}
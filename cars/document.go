@@ -0,0 +1,141 @@
+package cars
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Document types.
+const (
+	DocumentRegistration = "registration"
+	DocumentInsurance    = "insurance"
+	DocumentInspection   = "inspection"
+)
+
+var documentTypes = map[string]bool{
+	DocumentRegistration: true,
+	DocumentInsurance:    true,
+	DocumentInspection:   true,
+}
+
+// documentContentTypes are the types a document may be, as sniffed from its content. What the client says the
+// file is does not count.
+var documentContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+const (
+	MaxDocumentBytes = 10 << 20
+	// sniffLength is as much as http.DetectContentType looks at.
+	sniffLength           = 512
+	maxDocumentNameLength = 200
+	// renewalNotice is how long before a document expires its renewal reminder is due.
+	renewalNotice = 30
+)
+
+var ErrDocumentNotFound = errors.New("Error: Document not found")
+var ErrDocumentTooLarge = errors.New("Error: Documents can be at most 10 MB")
+
+type Document struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CarID       string             `json:"carId" bson:"carId"`
+	Type        string             `json:"type" bson:"type"`
+	Name        string             `json:"name" bson:"name"`
+	ContentType string             `json:"contentType" bson:"contentType"`
+	Size        int64              `json:"size" bson:"size"`
+	StorageKey  string             `json:"-" bson:"storageKey"`
+	Expires     *time.Time         `json:"expires,omitempty" bson:"expires,omitempty"`
+	Expired     bool               `json:"expired" bson:"-"` // Worked out when the documents are read
+	Email       string             `json:"email" bson:"email"`
+	Created     time.Time          `json:"created" bson:"created"`
+}
+
+// documentStorageKey is where the document's file is kept.
+func documentStorageKey(carID string, documentID primitive.ObjectID) string {
+	return "cars/" + carID + "/documents/" + documentID.Hex()
+}
+
+// CreateDocument is the form sent with an upload, the file itself is streamed separately.
+type CreateDocument struct {
+	Type    string
+	Name    string
+	Expires *time.Time
+}
+
+func (c *CreateDocument) Valid() error {
+	if !documentTypes[c.Type] {
+		return invalid("Error: Type must be registration, insurance or inspection")
+	}
+	// Only the base name is kept, browsers and tools sometimes send the whole path
+	c.Name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(c.Name, "\\", "/")))
+	if c.Name == "" || c.Name == "." || c.Name == "/" {
+		return invalid("Error: File name is missing")
+	}
+	if len(c.Name) > maxDocumentNameLength {
+		return invalid("Error: File name is too long")
+	}
+	return nil
+}
+
+// ParseDocumentExpiry takes a date or a time in RFC 3339. A date expires at the end of that day.
+func ParseDocumentExpiry(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	expires, endOfDay, err := parseCreatedBound(value)
+	if err != nil {
+		return nil, invalid("Error: Expires must be YYYY-MM-DD or RFC 3339")
+	}
+	if endOfDay {
+		expires = expires.Add(24*time.Hour - time.Millisecond)
+	}
+	return &expires, nil
+}
+
+// SniffDocument works out the content type from the start of the content and returns a reader over the whole
+// content, start included.
+func SniffDocument(content io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	if n == 0 {
+		return "", nil, invalid("Error: File is empty")
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	// DetectContentType can add parameters like charset, only the media type matters here
+	contentType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if !documentContentTypes[contentType] {
+		return "", nil, invalid("Error: Documents must be a PDF, JPEG or PNG")
+	}
+	return contentType, io.MultiReader(bytes.NewReader(head), content), nil
+}
+
+// renewalReminder is the reminder emailed ahead of the document expiring.
+func (d *Document) renewalReminder(now time.Time) Reminder {
+	dueDate := d.Expires.AddDate(0, 0, -renewalNotice)
+	if dueDate.Before(now) {
+		dueDate = now
+	}
+	return Reminder{
+		CarID:      d.CarID,
+		Title:      "Renew " + d.Type + " (expires " + d.Expires.Format("January 2, 2006") + ")",
+		DueDate:    &dueDate,
+		Status:     ReminderActive,
+		DocumentID: d.ID.Hex(),
+		Email:      d.Email,
+		Created:    now,
+	}
+}
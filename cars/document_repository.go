@@ -0,0 +1,86 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DocumentRepository struct {
+	db                 *mongo.Database
+	documentCollection string
+}
+
+func NewInstanceOfDocumentRepository(db *mongo.Database) DocumentRepository {
+	return DocumentRepository{db: db, documentCollection: "carDocuments"}
+}
+
+// CreateIndexes sets up the index used to list a car's documents. It is safe to call on every start.
+func (r *DocumentRepository) CreateIndexes() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "carId", Value: 1}, {Key: "type", Value: 1}, {Key: "created", Value: -1}},
+		Options: options.Index().SetName("documentsCarType"),
+	}
+	_, err := r.db.Collection(r.documentCollection).Indexes().CreateOne(context.TODO(), index)
+	return err
+}
+
+// List returns the car's documents by type, newest first. An empty type returns every type.
+func (r *DocumentRepository) List(carID string, documentType string) ([]Document, error) {
+	filter := bson.M{"carId": carID}
+	if documentType != "" {
+		filter["type"] = documentType
+	}
+	options := options.Find().SetSort(bson.D{{Key: "type", Value: 1}, {Key: "created", Value: -1}})
+	cursor, err := r.db.Collection(r.documentCollection).Find(context.TODO(), filter, options)
+	if err != nil {
+		return []Document{}, err
+	}
+	documents := []Document{}
+	if err := cursor.All(context.TODO(), &documents); err != nil {
+		return []Document{}, err
+	}
+	return documents, nil
+}
+
+func (r *DocumentRepository) Get(carID string, documentID string) (Document, error) {
+	docID, err := primitive.ObjectIDFromHex(documentID)
+	if err != nil {
+		return Document{}, ErrDocumentNotFound
+	}
+
+	var document Document
+	err = r.db.Collection(r.documentCollection).FindOne(context.TODO(), bson.M{"_id": docID, "carId": carID}).Decode(&document)
+	if err == mongo.ErrNoDocuments {
+		return Document{}, ErrDocumentNotFound
+	}
+	if err != nil {
+		return Document{}, err
+	}
+	return document, nil
+}
+
+// Save stores the document under its ID, which is picked beforehand so the file can be stored first.
+func (r *DocumentRepository) Save(document Document) error {
+	_, err := r.db.Collection(r.documentCollection).InsertOne(context.TODO(), document)
+	return err
+}
+
+func (r *DocumentRepository) Delete(carID string, documentID primitive.ObjectID) error {
+	result, err := r.db.Collection(r.documentCollection).DeleteOne(context.TODO(), bson.M{"_id": documentID, "carId": carID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrDocumentNotFound
+	}
+	return nil
+}
+
+// DeleteForCar removes every document of a car, used when the car is purged. The files are removed separately.
+func (r *DocumentRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.documentCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
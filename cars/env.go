@@ -0,0 +1,16 @@
+package cars
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetTrashRetention returns how long deleted cars stay in the trash before they are purged. Defaults to 30 days.
+func GetTrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("CAR_TRASH_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	return time.Hour * 24 * time.Duration(days)
+}
@@ -0,0 +1,55 @@
+package cars
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrCarChanged = errors.New("Error: The car was changed by someone else, please try again")
+var ErrPreconditionFailed = errors.New("Error: The car does not match If-Match, it was changed since it was read")
+
+// ETag identifies the version of the car. Every change to the car makes a new one.
+func (c *Car) ETag() string {
+	return `"` + strconv.Itoa(c.Version) + `"`
+}
+
+// MatchesIfMatch reports whether an If-Match header lists the ETag. If-Match uses the strong comparison, so a weak
+// ETag (W/"...") never matches.
+func MatchesIfMatch(header string, etag string) bool {
+	return matchesETag(header, etag, false)
+}
+
+// MatchesIfNoneMatch reports whether an If-None-Match header lists the ETag. If-None-Match uses the weak
+// comparison, so W/"3" matches "3".
+func MatchesIfNoneMatch(header string, etag string) bool {
+	return matchesETag(header, etag, true)
+}
+
+// matchesETag looks for the ETag in a header that is either * or a comma separated list of ETags.
+func matchesETag(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// versionFilter matches the car at the version. Cars from before versions have none and are version 0.
+func versionFilter(version int) interface{} {
+	if version == 0 {
+		return nil
+	}
+	return version
+}
@@ -0,0 +1,172 @@
+package cars
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+)
+
+// exportColumns is every column that can be exported and how to read it from a car. Numbers stay numbers so
+// spreadsheets can sum and sort them, and tags stay a list for NDJSON.
+var exportColumns = map[string]func(car Car) interface{}{
+	"id":             func(car Car) interface{} { return car.ID.Hex() },
+	"make":           func(car Car) interface{} { return car.Make },
+	"model":          func(car Car) interface{} { return car.Model },
+	"year":           func(car Car) interface{} { return car.Year },
+	"status":         func(car Car) interface{} { return car.CurrentStatus() },
+	"vin":            func(car Car) interface{} { return car.VIN },
+	"notes":          func(car Car) interface{} { return car.Notes },
+	"tags":           func(car Car) interface{} { return append([]string{}, car.Tags...) },
+	"email":          func(car Car) interface{} { return car.Email },
+	"organizationId": func(car Car) interface{} { return car.OrganizationID },
+	"created":        func(car Car) interface{} { return car.Created.UTC().Format(time.RFC3339) },
+}
+
+// DefaultExportColumns are exported when no columns are asked for.
+var DefaultExportColumns = []string{"id", "make", "model", "year", "status", "vin", "notes", "tags", "created"}
+
+func IsValidExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatNDJSON || format == ExportFormatXLSX
+}
+
+// ExportContentType is the content type of the format.
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv"
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+}
+
+// ParseExportColumns reads a comma separated list of columns, in the order they should be written.
+func ParseExportColumns(value string) ([]string, error) {
+	if value == "" {
+		return DefaultExportColumns, nil
+	}
+	columns := []string{}
+	seen := map[string]bool{}
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if _, ok := exportColumns[column]; !ok {
+			return nil, invalid("Error: Unknown export column " + column)
+		}
+		if seen[column] {
+			return nil, invalid("Error: Export column " + column + " is repeated")
+		}
+		seen[column] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// ExportValues reads the columns of a car in order.
+func ExportValues(car Car, columns []string) []interface{} {
+	values := []interface{}{}
+	for _, column := range columns {
+		values = append(values, exportColumns[column](car))
+	}
+	return values
+}
+
+// cellText is how a value is written in the text based formats. Tags are joined the same way the import splits
+// them.
+func cellText(value interface{}) string {
+	switch value := value.(type) {
+	case int:
+		return strconv.Itoa(value)
+	case []string:
+		return strings.Join(value, csvTagSeparator)
+	default:
+		return value.(string)
+	}
+}
+
+// Exporter writes cars to a file one row at a time, so the whole export never has to be in memory.
+type Exporter interface {
+	Begin() error
+	Write(values []interface{}) error
+	Close() error
+}
+
+func NewExporter(format string, w io.Writer, columns []string) Exporter {
+	switch format {
+	case ExportFormatCSV:
+		return &csvExporter{writer: csv.NewWriter(w), columns: columns}
+	case ExportFormatNDJSON:
+		return &ndjsonExporter{encoder: json.NewEncoder(w), columns: columns}
+	default:
+		return newXLSXExporter(w, columns)
+	}
+}
+
+type csvExporter struct {
+	writer  *csv.Writer
+	columns []string
+}
+
+func (e *csvExporter) Begin() error {
+	return e.writer.Write(e.columns)
+}
+
+func (e *csvExporter) Write(values []interface{}) error {
+	record := []string{}
+	for _, value := range values {
+		if _, ok := value.(int); ok {
+			record = append(record, cellText(value))
+			continue
+		}
+		record = append(record, escapeFormula(cellText(value)))
+	}
+	return e.writer.Write(record)
+}
+
+// formulaPrefixes are the characters that make a spreadsheet read a CSV cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula puts a ' in front of text that a spreadsheet would run as a formula, so a car's notes can't run
+// anything on the computer of whoever opens the export. Spreadsheets hide the '.
+func escapeFormula(text string) string {
+	if text != "" && strings.IndexByte(formulaPrefixes, text[0]) != -1 {
+		return "'" + text
+	}
+	return text
+}
+
+func (e *csvExporter) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonExporter struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (e *ndjsonExporter) Begin() error {
+	return nil
+}
+
+func (e *ndjsonExporter) Write(values []interface{}) error {
+	line := map[string]interface{}{}
+	for i, column := range e.columns {
+		line[column] = values[i]
+	}
+	return e.encoder.Encode(line)
+}
+
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package cars
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+func TestCSVExportEscapesFormulas(t *testing.T) {
+	columns := []string{"make", "model", "year", "notes", "tags"}
+	car := Car{
+		Make:  "=HYPERLINK(\"http://example.com\")",
+		Model: "+1",
+		Year:  2018,
+		Notes: "@SUM(A1)",
+		Tags:  []string{"-2", "family"},
+	}
+
+	var buffer bytes.Buffer
+	exporter := NewExporter(ExportFormatCSV, &buffer, columns)
+	if err := exporter.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Write(ExportValues(car, columns)); err != nil {
+		t.Fatal(err)
+	}
+	car = Car{Make: "Toyota", Model: "\tCorolla", Year: 2020, Notes: "\rcmd"}
+	if err := exporter.Write(ExportValues(car, columns)); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		columns,
+		{"'=HYPERLINK(\"http://example.com\")", "'+1", "2018", "'@SUM(A1)", "'-2;family"},
+		{"Toyota", "'\tCorolla", "2020", "'\rcmd", ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %q, want %q", records, want)
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := map[string]string{
+		"":         "",
+		"Mazda":    "Mazda",
+		"3":        "3",
+		"a=b":      "a=b",
+		"=1+1":     "'=1+1",
+		"+1":       "'+1",
+		"-1":       "'-1",
+		"@A1":      "'@A1",
+		"\tnotes":  "'\tnotes",
+		"\rnotes":  "'\rnotes",
+		"'already": "'already",
+	}
+	for text, want := range tests {
+		if got := escapeFormula(text); got != want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cars
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sortableFields is the whitelist of car fields that can be sorted on. The _id is left out since it is always
+// added as the last sort field.
+var sortableFields = map[string]bool{
+	"created": true,
+	"make":    true,
+	"model":   true,
+	"year":    true,
+	"status":  true,
+}
+
+// rangeSeparator splits the lower and upper bound of a range filter, e.g. year=2010..2015. Either bound can be
+// left off for an open range.
+const rangeSeparator = ".."
+
+const dateLayout = "2006-01-02"
+
+// ParseSort reads a sort like "-year,make" where a leading minus means descending.
+func ParseSort(sort string) ([]SortField, error) {
+	sortFields := []SortField{}
+	if sort == "" {
+		return sortFields, nil
+	}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		sortField := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			sortField = SortField{Field: part[1:], Descending: true}
+		} else if strings.HasPrefix(part, "+") {
+			sortField = SortField{Field: part[1:]}
+		}
+		if seen[sortField.Field] {
+			return nil, invalid("Error: Sort field " + sortField.Field + " is repeated")
+		}
+		seen[sortField.Field] = true
+		sortFields = append(sortFields, sortField)
+	}
+	return sortFields, nil
+}
+
+// ParseYearRange reads a single year ("2015") or a range ("2010..2015", "2010..", "..2015"). Zero means the
+// bound is not set.
+func ParseYearRange(value string) (int, int, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	if !strings.Contains(value, rangeSeparator) {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, 0, invalid("Error: Year must be a number or a range like 2010..2015")
+		}
+		return year, year, nil
+	}
+
+	bounds := strings.SplitN(value, rangeSeparator, 2)
+	years := [2]int{}
+	for i, bound := range bounds {
+		if bound == "" {
+			continue
+		}
+		year, err := strconv.Atoi(bound)
+		if err != nil {
+			return 0, 0, invalid("Error: Year must be a number or a range like 2010..2015")
+		}
+		years[i] = year
+	}
+	return years[0], years[1], nil
+}
+
+// ParseCreatedRange reads a range of dates ("2024-01-01..2024-01-31") or times in RFC 3339. A date as the upper
+// bound includes the whole of that day. Zero times mean the bound is not set.
+func ParseCreatedRange(value string) (time.Time, time.Time, error) {
+	if value == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	if !strings.Contains(value, rangeSeparator) {
+		return time.Time{}, time.Time{}, invalid("Error: Created must be a range like 2024-01-01..2024-01-31")
+	}
+
+	bounds := strings.SplitN(value, rangeSeparator, 2)
+	from, _, err := parseCreatedBound(bounds[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, isDate, err := parseCreatedBound(bounds[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if isDate {
+		to = to.Add(24*time.Hour - time.Millisecond)
+	}
+	return from, to, nil
+}
+
+func parseCreatedBound(bound string) (time.Time, bool, error) {
+	if bound == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(dateLayout, bound); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, bound)
+	if err != nil {
+		return time.Time{}, false, invalid("Error: Created dates must be YYYY-MM-DD or RFC 3339")
+	}
+	return t, false, nil
+}
+
+// ParseStatuses reads a comma separated set of statuses, e.g. status=active,for-sale.
+func ParseStatuses(value string) []string {
+	statuses := []string{}
+	if value == "" {
+		return statuses
+	}
+	for _, status := range strings.Split(value, ",") {
+		statuses = append(statuses, strings.TrimSpace(status))
+	}
+	return statuses
+}
@@ -0,0 +1,193 @@
+package cars
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fuel log kinds. Distances are in kilometres, fuel in litres and charging in kWh.
+const (
+	FuelKindFuel   = "fuel"
+	FuelKindCharge = "charge"
+)
+
+var ErrFuelLogNotFound = errors.New("Error: Fuel log not found")
+
+// FuelLog is one fill up or charging session. Price is the total paid in the minor unit of the currency.
+type FuelLog struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CarID    string             `json:"carId" bson:"carId"`
+	Kind     string             `json:"kind" bson:"kind"`
+	Date     time.Time          `json:"date" bson:"date"`
+	Odometer int                `json:"odometer" bson:"odometer"`
+	Volume   float64            `json:"volume,omitempty" bson:"volume,omitempty"` // Litres, for fuel
+	KWh      float64            `json:"kWh,omitempty" bson:"kWh,omitempty"`       // For charging
+	Price    int64              `json:"price" bson:"price"`
+	Currency string             `json:"currency,omitempty" bson:"currency,omitempty"`
+	FullTank bool               `json:"fullTank" bson:"fullTank"` // Filled or charged to full, needed to work out consumption
+	Email    string             `json:"email" bson:"email"`       // Who added the log
+	Created  time.Time          `json:"created" bson:"created"`
+}
+
+// amount is the fuel or energy added, whichever the kind uses.
+func (f *FuelLog) amount() float64 {
+	if f.Kind == FuelKindCharge {
+		return f.KWh
+	}
+	return f.Volume
+}
+
+type CreateFuelLog struct {
+	Kind     string    `json:"kind"` // Defaults to fuel
+	Date     time.Time `json:"date"`
+	Odometer int       `json:"odometer"`
+	Volume   float64   `json:"volume"`
+	KWh      float64   `json:"kWh"`
+	Price    int64     `json:"price"`
+	Currency string    `json:"currency"`
+	FullTank bool      `json:"fullTank"`
+}
+
+func (c *CreateFuelLog) Valid() error {
+	if c.Kind == "" {
+		c.Kind = FuelKindFuel
+	}
+	if c.Kind != FuelKindFuel && c.Kind != FuelKindCharge {
+		return invalid("Error: Kind must be fuel or charge")
+	}
+	if c.Date.IsZero() {
+		return invalid("Error: Date is missing")
+	}
+	if c.Date.After(time.Now().Add(24 * time.Hour)) {
+		return invalid("Error: Date can not be in the future")
+	}
+	if c.Odometer <= 0 {
+		return invalid("Error: Odometer is missing")
+	}
+	if c.Kind == FuelKindFuel && (c.Volume <= 0 || c.KWh != 0) {
+		return invalid("Error: A fill up needs a volume in litres and no kWh")
+	}
+	if c.Kind == FuelKindCharge && (c.KWh <= 0 || c.Volume != 0) {
+		return invalid("Error: A charge needs kWh and no volume")
+	}
+	if c.Price < 0 {
+		return invalid("Error: Price can not be negative")
+	}
+	if c.Currency != "" && !currencyPattern.MatchString(c.Currency) {
+		return invalid("Error: Currency must be a three letter code like USD")
+	}
+	return nil
+}
+
+// FuelStats is the consumption of one kind of energy. Consumption is per 100 km, in L/100km for fuel and
+// kWh/100km for charging, and is only worked out between full fills: everything added after one full fill up to
+// and including the next was used over the distance between them.
+type FuelStats struct {
+	Kind         string           `json:"kind"`
+	Unit         string           `json:"unit"`
+	Distance     int              `json:"distance"`
+	Amount       float64          `json:"amount"`
+	Consumption  float64          `json:"consumption"`
+	Cost         int64            `json:"cost"`
+	Currency     string           `json:"currency,omitempty"`
+	CostPer100Km *float64         `json:"costPer100Km,omitempty"` // Left out when the logs are in more than one currency
+	Trend        []FuelTrendPoint `json:"trend"`
+}
+
+// FuelTrendPoint is the consumption over the stretches between full fills that ended in the month.
+type FuelTrendPoint struct {
+	Month       string  `json:"month"` // YYYY-MM
+	Distance    int     `json:"distance"`
+	Amount      float64 `json:"amount"`
+	Consumption float64 `json:"consumption"`
+}
+
+// ComputeFuelStats works out the stats for each kind with enough full fills. Logs must be oldest first.
+func ComputeFuelStats(logs []FuelLog) []FuelStats {
+	stats := []FuelStats{}
+	for _, kind := range []string{FuelKindFuel, FuelKindCharge} {
+		kindLogs := []FuelLog{}
+		for _, log := range logs {
+			if log.Kind == kind {
+				kindLogs = append(kindLogs, log)
+			}
+		}
+		if kindStats, ok := computeKindStats(kind, kindLogs); ok {
+			stats = append(stats, kindStats)
+		}
+	}
+	return stats
+}
+
+func computeKindStats(kind string, logs []FuelLog) (FuelStats, bool) {
+	stats := FuelStats{Kind: kind, Unit: "L/100km", Trend: []FuelTrendPoint{}}
+	if kind == FuelKindCharge {
+		stats.Unit = "kWh/100km"
+	}
+
+	months := map[string]*FuelTrendPoint{}
+	currencies := map[string]bool{}
+	var baseline *FuelLog
+	amount, cost := 0.0, int64(0)
+	for i := range logs {
+		log := logs[i]
+		if baseline == nil {
+			// Nothing before the first full fill can be measured
+			if log.FullTank {
+				baseline = &logs[i]
+			}
+			continue
+		}
+		amount += log.amount()
+		cost += log.Price
+		currencies[log.Currency] = true
+		if !log.FullTank {
+			continue
+		}
+
+		distance := log.Odometer - baseline.Odometer
+		if distance > 0 {
+			stats.Distance += distance
+			stats.Amount += amount
+			stats.Cost += cost
+
+			month := log.Date.UTC().Format("2006-01")
+			point, ok := months[month]
+			if !ok {
+				point = &FuelTrendPoint{Month: month}
+				months[month] = point
+				stats.Trend = append(stats.Trend, FuelTrendPoint{Month: month})
+			}
+			point.Distance += distance
+			point.Amount += amount
+		}
+		baseline = &logs[i]
+		amount, cost = 0, 0
+	}
+	if stats.Distance == 0 {
+		return FuelStats{}, false
+	}
+
+	stats.Consumption = per100Km(stats.Amount, stats.Distance)
+	if len(currencies) == 1 {
+		for currency := range currencies {
+			stats.Currency = currency
+		}
+		costPer100Km := per100Km(float64(stats.Cost), stats.Distance)
+		stats.CostPer100Km = &costPer100Km
+	}
+	for i, point := range stats.Trend {
+		monthPoint := months[point.Month]
+		monthPoint.Consumption = per100Km(monthPoint.Amount, monthPoint.Distance)
+		stats.Trend[i] = *monthPoint
+	}
+	return stats, true
+}
+
+// per100Km rounds to two decimals, more would only be noise from the readings.
+func per100Km(amount float64, distance int) float64 {
+	return math.Round(amount/float64(distance)*100*100) / 100
+}
@@ -0,0 +1,100 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type FuelRepository struct {
+	db             *mongo.Database
+	fuelCollection string
+}
+
+func NewInstanceOfFuelRepository(db *mongo.Database) FuelRepository {
+	return FuelRepository{db: db, fuelCollection: "carFuelLogs"}
+}
+
+// CreateIndexes sets up the index used to read a car's logs in order. It is safe to call on every start.
+func (r *FuelRepository) CreateIndexes() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "carId", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetName("fuelLogsCarDate"),
+	}
+	_, err := r.db.Collection(r.fuelCollection).Indexes().CreateOne(context.TODO(), index)
+	return err
+}
+
+// List returns the car's logs, oldest first.
+func (r *FuelRepository) List(carID string) ([]FuelLog, error) {
+	options := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "odometer", Value: 1}})
+	cursor, err := r.db.Collection(r.fuelCollection).Find(context.TODO(), bson.M{"carId": carID}, options)
+	if err != nil {
+		return []FuelLog{}, err
+	}
+	logs := []FuelLog{}
+	if err := cursor.All(context.TODO(), &logs); err != nil {
+		return []FuelLog{}, err
+	}
+	return logs, nil
+}
+
+// Neighbours finds the logs just before and just after the date, so a new log's odometer can be checked against
+// them. Either is nil when there is no log on that side.
+func (r *FuelRepository) Neighbours(carID string, date time.Time) (*FuelLog, *FuelLog, error) {
+	before, err := r.findOne(bson.M{"carId": carID, "date": bson.M{"$lte": date}}, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := r.findOne(bson.M{"carId": carID, "date": bson.M{"$gt": date}}, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+func (r *FuelRepository) findOne(filter bson.M, direction int) (*FuelLog, error) {
+	options := options.FindOne().SetSort(bson.D{{Key: "date", Value: direction}, {Key: "odometer", Value: direction}})
+	var log FuelLog
+	err := r.db.Collection(r.fuelCollection).FindOne(context.TODO(), filter, options).Decode(&log)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *FuelRepository) Save(log FuelLog) (primitive.ObjectID, error) {
+	insertResult, err := r.db.Collection(r.fuelCollection).InsertOne(context.TODO(), log)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *FuelRepository) Delete(carID string, logID string) error {
+	docID, err := primitive.ObjectIDFromHex(logID)
+	if err != nil {
+		return ErrFuelLogNotFound
+	}
+
+	result, err := r.db.Collection(r.fuelCollection).DeleteOne(context.TODO(), bson.M{"_id": docID, "carId": carID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrFuelLogNotFound
+	}
+	return nil
+}
+
+// DeleteForCar removes every log of a car, used when the car is purged.
+func (r *FuelRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.fuelCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
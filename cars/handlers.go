@@ -2,14 +2,19 @@ package cars
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"go-boilerplate/logging"
 	"go-boilerplate/user"
+	"io"
+	"mime"
+	"net/http"
 	"strconv"
-	// "strings"
+	"strings"
+	"time"
 )
 
 type Handlers struct {
@@ -33,11 +38,58 @@ func (u *Handlers) GetSession(c *gin.Context) (user.Session, bool) {
 	return session, true
 }
 
+// errorStatuses are the status codes for the errors the services return. Validation errors are a bad request and
+// any other error is a 500.
+var errorStatuses = map[error]int{
+	ErrNotPermitted:          403,
+	ErrCarNotFound:           404,
+	ErrTransferNotFound:      404,
+	ErrImportNotFound:        404,
+	ErrServiceRecordNotFound: 404,
+	ErrFuelLogNotFound:       404,
+	ErrReadingNotFound:       404,
+	ErrReminderNotFound:      404,
+	ErrDocumentNotFound:      404,
+	ErrPhotoNotFound:         404,
+	ErrRevisionNotFound:      404,
+	ErrDuplicateVIN:          409,
+	ErrCarChanged:            409,
+	ErrTransferConflict:      409,
+	errRevisionNumberTaken:   409,
+	ErrReminderCompleted:     409,
+	ErrNothingToRevert:       409,
+	ErrTooManyPhotos:         409,
+	ErrPreconditionFailed:    412,
+	ErrDocumentTooLarge:      413,
+	ErrPhotoTooLarge:         413,
+	ErrUnsupportedPatch:      415,
+}
+
+// writeError responds with the status code for the error. Errors that are not known or a ValidationError are a
+// 500 without the details, since they can hold file paths or database messages, and are logged.
+func (u *Handlers) writeError(ctx context.Context, c *gin.Context, err error) {
+	for known, status := range errorStatuses {
+		if errors.Is(err, known) {
+			c.JSON(status, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		c.JSON(400, gin.H{"message": validationError.Message})
+		return
+	}
+	u.logger.Error(ctx, "request failed", err)
+	c.JSON(500, gin.H{"message": "Error: Something went wrong, please try again"})
+}
+
 func (u *Handlers) GetAll(c *gin.Context) {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
 	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetAll")
 	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	u.logger.Info(ctx, "Called")
 
@@ -47,54 +99,199 @@ func (u *Handlers) GetAll(c *gin.Context) {
 		return
 	}
 
-	page := c.DefaultQuery("page", "1")
+	query, err := ParseListCarQuery(c)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	v := validator.New()
+	if err := v.Struct(query); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+	if err := query.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	carPage, err := u.carsService.GetAll(ctx, session, query)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	writeCarPage(c, "Cars retrieved", query, carPage)
+	return
+}
+
+func (u *Handlers) GetTrash(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetTrash")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	query, err := ParseListCarQuery(c)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	if err := query.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	carPage, err := u.carsService.GetTrash(ctx, session, query)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	writeCarPage(c, "Trash retrieved", query, carPage)
+	return
+}
+
+// writeCarPage responds with a page of cars, with the cursors or page number depending on the paging used.
+func writeCarPage(c *gin.Context, message string, query ListCarQuery, carPage CarPage) {
+	response := gin.H{"message": message, "cars": carPage.Cars, "limit": query.Limit}
+	if query.Page > 0 {
+		response["page"] = query.Page
+	} else {
+		response["nextCursor"] = carPage.NextCursor
+		response["prevCursor"] = carPage.PrevCursor
+		setLinkHeader(c, carPage)
+	}
+	if carPage.Total != nil {
+		response["total"] = *carPage.Total
+	}
+	c.JSON(200, response)
+}
+
+// ParseListCarQuery reads the paging, filter and sort query params shared by listing and exporting cars.
+func ParseListCarQuery(c *gin.Context) (ListCarQuery, error) {
+	page := c.DefaultQuery("page", "1") // Page/limit paging unless a cursor or paging=cursor is given
 	limit := c.DefaultQuery("limit", "25")
+	cursor := c.DefaultQuery("cursor", "")
+	includeTotal := c.DefaultQuery("includeTotal", "false")
 	make := c.DefaultQuery("make", "")
 	model := c.DefaultQuery("model", "")
-	year := c.DefaultQuery("year", "0")
+	search := c.DefaultQuery("q", "") // Free text search, sorted by relevance unless a sort is given
+	year := c.DefaultQuery("year", "") // A year or a range like 2010..2015
+	status := c.DefaultQuery("status", "") // Comma separated, e.g. active,for-sale
+	created := c.DefaultQuery("created", "") // A range like 2024-01-01..2024-01-31
+	sort := c.DefaultQuery("sort", "") // Comma separated, minus for descending, e.g. -year,make
+	organizationID := c.DefaultQuery("organizationId", "")
 
-	yearInt, err := strconv.Atoi(year)
+	// Cursor paging is opted into, so clients from before cursors get the same pages they always did
+	_, pageGiven := c.GetQuery("page")
+	if !pageGiven && (cursor != "" || c.Query("paging") == "cursor") {
+		page = "0"
+	}
+
+	yearMin, yearMax, err := ParseYearRange(year)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
-		return
+		return ListCarQuery{}, err
+	}
+
+	createdFrom, createdTo, err := ParseCreatedRange(created)
+	if err != nil {
+		return ListCarQuery{}, err
+	}
+
+	sortFields, err := ParseSort(sort)
+	if err != nil {
+		return ListCarQuery{}, err
 	}
 
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
-		return
+		return ListCarQuery{}, invalid("Error: Page must be a whole number")
+	}
+	// Page 0 picks cursor paging, which is only done above
+	if pageGiven && pageInt < 1 {
+		return ListCarQuery{}, invalid("Error: Page must be at least 1")
 	}
 
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
-		return
+		return ListCarQuery{}, invalid("Error: Limit must be a whole number")
 	}
 
-	query := ListCarQuery{
+	includeTotalBool, err := strconv.ParseBool(includeTotal)
+	if err != nil {
+		return ListCarQuery{}, invalid("Error: includeTotal must be true or false")
+	}
+
+	return ListCarQuery{
 		Page:  pageInt,
 		Limit: limitInt,
+		Cursor: cursor,
+		IncludeTotal: includeTotalBool,
 		Make:  make,
 		Model: model,
-		Year:  yearInt,
+		Search: search,
+		YearMin: yearMin,
+		YearMax: yearMax,
+		Statuses: ParseStatuses(status),
+		CreatedFrom: createdFrom,
+		CreatedTo: createdTo,
+		Sort: sortFields,
+		OrganizationID: organizationID,
+	}, nil
+}
+
+// setLinkHeader adds RFC 8288 next/prev links that repeat the current request with the cursor swapped.
+func setLinkHeader(c *gin.Context, page CarPage) {
+	links := []string{}
+	for _, rel := range []string{"next", "prev"} {
+		cursor := page.NextCursor
+		if rel == "prev" {
+			cursor = page.PrevCursor
+		}
+		if cursor == "" {
+			continue
+		}
+		link := *c.Request.URL
+		values := link.Query()
+		values.Set("cursor", cursor)
+		values.Del("page")
+		link.RawQuery = values.Encode()
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", link.RequestURI(), rel))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
 	}
+}
 
-	v := validator.New()
-	if err := v.Struct(query); err != nil {
-		fmt.Print("Validation failed.")
-		c.JSON(400, gin.H{"message": err.Error()})
+// GetStats counts the user's cars, or an organization's with the organizationId query.
+func (u *Handlers) GetStats(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetStats")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	organizationID := c.DefaultQuery("organizationId", "")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
 		return
 	}
 
-	cars, err := u.carsService.GetAll(ctx, session, query)
+	stats, err := u.carsService.GetStats(ctx, session, organizationID)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
 		return
 	}
-	if cars == nil {
-		cars = []Car{}
-	}
-	c.JSON(200, gin.H{"message": "Cars retrieved", "cars": cars})
+	c.JSON(200, gin.H{"message": "Stats retrieved", "stats": stats})
 	return
 }
 
@@ -103,6 +300,8 @@ func (u *Handlers) GetByID(c *gin.Context) {
 	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
 	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetByID")
 	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	carsID := c.Param("id")
 
@@ -114,7 +313,12 @@ func (u *Handlers) GetByID(c *gin.Context) {
 
 	car, err := u.carsService.GetByID(ctx, session, carsID)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Header("ETag", car.ETag())
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && MatchesIfNoneMatch(ifNoneMatch, car.ETag()) {
+		c.Status(304)
 		return
 	}
 	c.JSON(200, gin.H{"message": "Car retrieved", "car": car})
@@ -126,15 +330,17 @@ func (u *Handlers) Create(c *gin.Context) {
 	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
 	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Create")
 	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	var body CreateCar
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, invalid(err.Error()))
 		return
 	}
 
 	if err := body.Valid(); err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
 		return
 	}
 
@@ -146,16 +352,17 @@ func (u *Handlers) Create(c *gin.Context) {
 
 	v := validator.New()
 	if err := v.Struct(body); err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, invalid(err.Error()))
 		return
 	}
 
-	err := u.carsService.Create(ctx, session, body)
+	car, err := u.carsService.Create(ctx, session, body)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
 		return
 	}
-	c.JSON(200, gin.H{"message": "Created car"})
+	c.Header("Location", "/cars/"+car.ID.Hex())
+	c.JSON(201, gin.H{"message": "Created car", "car": car})
 	return
 }
 
@@ -164,17 +371,19 @@ func (u *Handlers) Update(c *gin.Context) {
 	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
 	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Update")
 	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	carsID := c.Param("id")
 
 	var body UpdateCar
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, invalid(err.Error()))
 		return
 	}
 
 	if err := body.Valid(); err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
 		return
 	}
 
@@ -186,13 +395,52 @@ func (u *Handlers) Update(c *gin.Context) {
 
 	v := validator.New()
 	if err := v.Struct(body); err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	err := u.carsService.Update(ctx, session, carsID, body, c.GetHeader("If-Match"))
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Updated car"})
+	return
+}
+
+// Patch takes a JSON Merge Patch or a JSON Patch, told apart by the content type.
+func (u *Handlers) Patch(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Patch")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	contentType := c.ContentType()
+	if contentType != MergePatchContentType && contentType != JSONPatchContentType {
+		c.Header("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+		u.writeError(ctx, c, ErrUnsupportedPatch)
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxPatchBytes))
+	if err != nil {
+		c.JSON(400, gin.H{"message": "Error: The patch could not be read, it may be over 1 MB"})
 		return
 	}
 
-	err := u.carsService.Update(ctx, session, carsID, body)
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err = u.carsService.Patch(ctx, session, carsID, contentType, patch, c.GetHeader("If-Match"))
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
 		return
 	}
 	c.JSON(200, gin.H{"message": "Updated car"})
@@ -204,6 +452,33 @@ func (u *Handlers) Delete(c *gin.Context) {
 	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
 	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Delete")
 	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	carsID := c.Param("id")
+
+	err := u.carsService.Delete(ctx, session, carsID, c.GetHeader("If-Match"))
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) Restore(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Restore")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	session, exists := u.GetSession(c)
 	if !exists {
@@ -213,11 +488,1198 @@ func (u *Handlers) Delete(c *gin.Context) {
 
 	carsID := c.Param("id")
 
-	err := u.carsService.Delete(ctx, session, carsID)
+	car, err := u.carsService.Restore(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Restored car", "car": car})
+	return
+}
+
+func (u *Handlers) GetStatusHistory(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetStatusHistory")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	history, err := u.carsService.GetStatusHistory(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Status history retrieved", "statusHistory": history})
+	return
+}
+
+func (u *Handlers) GetRevisions(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetRevisions")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	revisions, err := u.carsService.GetRevisions(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Revisions retrieved", "revisions": revisions})
+	return
+}
+
+func (u *Handlers) RevertToRevision(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "RevertToRevision")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	number := c.Param("number")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.RevertToRevision(ctx, session, carsID, number)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Car reverted"})
+	return
+}
+
+// Export streams the cars matching the same filters as GetAll. Paging is ignored, every matching car is written.
+func (u *Handlers) Export(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Export")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	if !IsValidExportFormat(format) {
+		c.JSON(400, gin.H{"message": "Error: Format must be csv, ndjson or xlsx"})
+		return
+	}
+
+	columns, err := ParseExportColumns(c.DefaultQuery("columns", ""))
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	query, err := ParseListCarQuery(c)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	if err := query.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	// Nothing is written until the first car, so errors before then can still be sent as JSON
+	exporter := NewExporter(format, c.Writer, columns)
+	started := false
+	begin := func() error {
+		started = true
+		c.Header("Content-Type", ExportContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"cars-%s.%s\"", time.Now().Format("20060102"), format))
+		c.Status(200)
+		return exporter.Begin()
+	}
+
+	err = u.carsService.Export(ctx, session, query, format, func(car Car) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		return exporter.Write(ExportValues(car, columns))
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err != nil {
+		if !started {
+			u.writeError(ctx, c, err)
+			return
+		}
+		// The response is already under way, the client sees a cut off file
+		u.logger.Warning(ctx, "export stopped part way", err)
+	}
+	return
+}
+
+// Import takes a CSV or NDJSON file of cars as the request body. The format comes from the format query or
+// the content type.
+func (u *Handlers) Import(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Import")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "")
+	dryRun := c.DefaultQuery("dryRun", "false")
+	organizationID := c.DefaultQuery("organizationId", "")
+
+	if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = ImportFormatCSV
+		case "application/x-ndjson":
+			format = ImportFormatNDJSON
+		}
+	}
+	if !IsValidImportFormat(format) {
+		c.JSON(400, gin.H{"message": "Error: Format must be csv or ndjson"})
+		return
+	}
+
+	dryRunBool, err := strconv.ParseBool(dryRun)
+	if err != nil {
+		u.writeError(ctx, c, invalid("Error: dryRun must be true or false"))
+		return
+	}
+
+	// Read the whole file now, the import carries on after the request is done
+	rows, err := ParseImport(format, http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportBytes))
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	body := ImportCars{
+		Format:         format,
+		DryRun:         dryRunBool,
+		OrganizationID: organizationID,
+		Rows:           rows,
+	}
+	job, err := u.carsService.StartImport(ctx, session, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Header("Location", "/cars/import/"+job.ID.Hex())
+	c.JSON(202, gin.H{"message": "Import started", "import": job})
+	return
+}
+
+func (u *Handlers) GetImport(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetImport")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	jobID := c.Param("jobId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	job, err := u.carsService.GetImport(ctx, session, jobID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Import retrieved", "import": job})
+	return
+}
+
+func (u *Handlers) GetServiceRecords(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetServiceRecords")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	year := c.DefaultQuery("year", "0")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	yearInt, err := strconv.Atoi(year)
+	if err != nil {
+		u.writeError(ctx, c, invalid("Error: Year must be a number"))
+		return
+	}
+
+	records, totals, err := u.carsService.GetServiceRecords(ctx, session, carsID, yearInt)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Service records retrieved", "services": records, "totals": totals})
+	return
+}
+
+func (u *Handlers) GetServiceRecord(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetServiceRecord")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	recordID := c.Param("recordId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	record, err := u.carsService.GetServiceRecord(ctx, session, carsID, recordID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Service record retrieved", "service": record})
+	return
+}
+
+func (u *Handlers) CreateServiceRecord(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "CreateServiceRecord")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	var body CreateServiceRecord
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	record, err := u.carsService.CreateServiceRecord(ctx, session, carsID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Header("Location", "/cars/"+carsID+"/services/"+record.ID.Hex())
+	c.JSON(201, gin.H{"message": "Created service record", "service": record})
+	return
+}
+
+func (u *Handlers) UpdateServiceRecord(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "UpdateServiceRecord")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	recordID := c.Param("recordId")
+
+	var body UpdateServiceRecord
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.UpdateServiceRecord(ctx, session, carsID, recordID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Updated service record"})
+	return
+}
+
+func (u *Handlers) DeleteServiceRecord(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeleteServiceRecord")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	recordID := c.Param("recordId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeleteServiceRecord(ctx, session, carsID, recordID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) GetFuelLogs(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetFuelLogs")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	logs, stats, err := u.carsService.GetFuelLogs(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Fuel logs retrieved", "fuel": logs, "stats": stats})
+	return
+}
+
+func (u *Handlers) CreateFuelLog(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "CreateFuelLog")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	var body CreateFuelLog
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	log, err := u.carsService.CreateFuelLog(ctx, session, carsID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(201, gin.H{"message": "Created fuel log", "fuel": log})
+	return
+}
+
+func (u *Handlers) DeleteFuelLog(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeleteFuelLog")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	logID := c.Param("logId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeleteFuelLog(ctx, session, carsID, logID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) GetMileage(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetMileage")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	mileage, err := u.carsService.GetMileage(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Mileage retrieved", "mileage": mileage})
+	return
+}
+
+func (u *Handlers) CreateOdometerReading(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "CreateOdometerReading")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	var body CreateOdometerReading
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	reading, err := u.carsService.CreateOdometerReading(ctx, session, carsID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(201, gin.H{"message": "Created odometer reading", "reading": reading})
+	return
+}
+
+func (u *Handlers) DeleteOdometerReading(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeleteOdometerReading")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	readingID := c.Param("readingId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeleteOdometerReading(ctx, session, carsID, readingID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) GetReminders(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetReminders")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	reminders, err := u.carsService.GetReminders(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Reminders retrieved", "reminders": reminders})
+	return
+}
+
+func (u *Handlers) CreateReminder(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "CreateReminder")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	var body CreateReminder
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	reminder, err := u.carsService.CreateReminder(ctx, session, carsID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(201, gin.H{"message": "Created reminder", "reminder": reminder})
+	return
+}
+
+func (u *Handlers) SnoozeReminder(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "SnoozeReminder")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	reminderID := c.Param("reminderId")
+
+	var body SnoozeReminder
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	reminder, err := u.carsService.SnoozeReminder(ctx, session, carsID, reminderID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Snoozed reminder", "reminder": reminder})
+	return
+}
+
+func (u *Handlers) CompleteReminder(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "CompleteReminder")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	reminderID := c.Param("reminderId")
+
+	// The body is optional, completing defaults to now and the latest odometer reading
+	var body CompleteReminder
+	if err := c.ShouldBindJSON(&body); err != nil && err != io.EOF {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	reminder, err := u.carsService.CompleteReminder(ctx, session, carsID, reminderID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Completed reminder", "reminder": reminder})
+	return
+}
+
+func (u *Handlers) DeleteReminder(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeleteReminder")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	reminderID := c.Param("reminderId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeleteReminder(ctx, session, carsID, reminderID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) GetDocuments(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetDocuments")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	documentType := c.DefaultQuery("type", "")
+
+	if documentType != "" && !documentTypes[documentType] {
+		c.JSON(400, gin.H{"message": "Error: Type must be registration, insurance or inspection"})
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	documents, err := u.carsService.GetDocuments(ctx, session, carsID, documentType)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Documents retrieved", "documents": documents})
+	return
+}
+
+// UploadDocument takes a multipart form with the file, its type and optionally a name and expiry date.
+func (u *Handlers) UploadDocument(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "UploadDocument")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	// Leave some room for the rest of the form
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxDocumentBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"message": "Error: The file could not be read, it may be over 10 MB"})
+		return
+	}
+	if fileHeader.Size > MaxDocumentBytes {
+		u.writeError(ctx, c, ErrDocumentTooLarge)
+		return
+	}
+
+	expires, err := ParseDocumentExpiry(c.PostForm("expires"))
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	body := CreateDocument{
+		Type:    c.PostForm("type"),
+		Name:    c.DefaultPostForm("name", fileHeader.Filename),
+		Expires: expires,
+	}
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	defer file.Close()
+
+	document, err := u.carsService.UploadDocument(ctx, session, carsID, body, file)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(201, gin.H{"message": "Uploaded document", "document": document})
+	return
+}
+
+func (u *Handlers) DownloadDocument(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DownloadDocument")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	documentID := c.Param("documentId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	document, file, err := u.carsService.DownloadDocument(ctx, session, carsID, documentID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	defer file.Close()
+
+	headers := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": document.Name}),
+		"X-Content-Type-Options": "nosniff",
+	}
+	c.DataFromReader(200, document.Size, document.ContentType, file, headers)
+	return
+}
+
+func (u *Handlers) DeleteDocument(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeleteDocument")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	documentID := c.Param("documentId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeleteDocument(ctx, session, carsID, documentID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) GetPhotos(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetPhotos")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	photos, err := u.carsService.GetPhotos(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Photos retrieved", "photos": photos})
+	return
+}
+
+// UploadPhoto takes a multipart form with the photo as file.
+func (u *Handlers) UploadPhoto(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "UploadPhoto")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	// Leave some room for the rest of the form
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxPhotoBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"message": "Error: The photo could not be read, it may be over 15 MB"})
+		return
+	}
+	if fileHeader.Size > MaxPhotoBytes {
+		u.writeError(ctx, c, ErrPhotoTooLarge)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	defer file.Close()
+
+	photo, err := u.carsService.UploadPhoto(ctx, session, carsID, file)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(201, gin.H{"message": "Uploaded photo", "photo": photo})
+	return
+}
+
+func (u *Handlers) DownloadPhoto(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DownloadPhoto")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	photoID := c.Param("photoId")
+	variantName := c.Param("variant")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	photo, variant, file, err := u.carsService.DownloadPhoto(ctx, session, carsID, photoID, variantName)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	defer file.Close()
+
+	// A photo's variants never change, only get deleted
+	headers := map[string]string{
+		"Cache-Control":          "private, max-age=86400",
+		"X-Content-Type-Options": "nosniff",
+	}
+	c.DataFromReader(200, variant.Size, photo.ContentType, file, headers)
+	return
+}
+
+func (u *Handlers) SetPrimaryPhoto(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "SetPrimaryPhoto")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	photoID := c.Param("photoId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	photo, err := u.carsService.SetPrimaryPhoto(ctx, session, carsID, photoID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Set primary photo", "photo": photo})
+	return
+}
+
+func (u *Handlers) DeletePhoto(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeletePhoto")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+	photoID := c.Param("photoId")
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeletePhoto(ctx, session, carsID, photoID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.Status(204)
+	return
+}
+
+func (u *Handlers) InitiateTransfer(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "InitiateTransfer")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	carsID := c.Param("id")
+
+	var body CreateTransfer
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	transfer, err := u.carsService.InitiateTransfer(ctx, session, carsID, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(201, gin.H{"message": "Transfer requested", "transfer": transfer})
+	return
+}
+
+func (u *Handlers) CancelTransfer(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "CancelTransfer")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	carsID := c.Param("id")
+
+	err := u.carsService.CancelTransfer(ctx, session, carsID)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Transfer cancelled"})
+	return
+}
+
+func (u *Handlers) GetTransfers(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetTransfers")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	transfers, err := u.carsService.GetTransfers(ctx, session)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Transfers retrieved", "transfers": transfers})
+	return
+}
+
+func (u *Handlers) AcceptTransfer(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "AcceptTransfer")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	var body RespondTransfer
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	car, err := u.carsService.AcceptTransfer(ctx, session, body)
+	if err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Transfer accepted", "car": car})
+	return
+}
+
+func (u *Handlers) DeclineTransfer(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "DeclineTransfer")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	var body RespondTransfer
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.writeError(ctx, c, invalid(err.Error()))
+		return
+	}
+
+	if err := body.Valid(); err != nil {
+		u.writeError(ctx, c, err)
+		return
+	}
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		c.JSON(403, gin.H{"message": "error: unauthorized"})
+		return
+	}
+
+	err := u.carsService.DeclineTransfer(ctx, session, body)
 	if err != nil {
-		c.JSON(400, gin.H{"message": err.Error()})
+		u.writeError(ctx, c, err)
 		return
 	}
-	c.JSON(200, gin.H{"message": "Deleted car"})
+	c.JSON(200, gin.H{"message": "Transfer declined"})
 	return
 }
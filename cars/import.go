@@ -0,0 +1,223 @@
+package cars
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Import formats
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// Import job statuses. Rows that fail are reported on the job, they do not fail the whole import. An import is
+// only failed when it stopped part way, because of a bug or the server restarting.
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+const (
+	MaxImportBytes = 10 << 20 // 10 MB
+	maxImportRows  = 5000
+	// maxImportErrors caps the row errors kept on a job so a bad file can not make the document too large.
+	maxImportErrors = 1000
+)
+
+// csvTagSeparator splits the tags column since commas already split the columns.
+const csvTagSeparator = ";"
+
+var ErrImportNotFound = errors.New("Error: Import not found")
+
+// errImportUnreadable is returned when the request body stops part way, most often because it is over MaxImportBytes.
+var errImportUnreadable = invalid("Error: The file could not be read, it may be over 10 MB")
+
+// importColumns are the CSV columns that can be imported. The first line of the file must name them.
+var importColumns = map[string]bool{
+	"make":  true,
+	"model": true,
+	"year":  true,
+	"vin":   true,
+	"notes": true,
+	"tags":  true,
+}
+
+// ImportRow is one car read from an import file. Row is the line in the file so people can find it, and Error
+// is set when the line could not be read at all.
+type ImportRow struct {
+	Row   int
+	Car   CreateCar
+	Error string
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row" bson:"row"`
+	Message string `json:"message" bson:"message"`
+}
+
+type ImportJob struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Email          string             `json:"email" bson:"email"` // Who started the import, only they can see it
+	OrganizationID string             `json:"organizationId,omitempty" bson:"organizationId,omitempty"`
+	Format         string             `json:"format" bson:"format"`
+	DryRun         bool               `json:"dryRun" bson:"dryRun"` // Only check the rows, nothing is saved
+	Status         string             `json:"status" bson:"status"`
+	TotalRows      int                `json:"totalRows" bson:"totalRows"`
+	ProcessedRows  int                `json:"processedRows" bson:"processedRows"`
+	CreatedCount   int                `json:"createdCount" bson:"createdCount"` // On a dry run, the cars that would be created
+	FailedCount    int                `json:"failedCount" bson:"failedCount"`
+	Errors         []ImportRowError   `json:"errors" bson:"errors"`
+	Created        time.Time          `json:"created" bson:"created"`
+	Finished       *time.Time         `json:"finished,omitempty" bson:"finished,omitempty"`
+}
+
+// addError keeps the row error unless the job already holds as many as it can.
+func (j *ImportJob) addError(row int, message string) {
+	j.FailedCount++
+	if len(j.Errors) < maxImportErrors {
+		j.Errors = append(j.Errors, ImportRowError{Row: row, Message: message})
+	}
+}
+
+type ImportCars struct {
+	Format         string
+	DryRun         bool
+	OrganizationID string // Optional, imports the cars into the organization
+	Rows           []ImportRow
+}
+
+func IsValidImportFormat(format string) bool {
+	return format == ImportFormatCSV || format == ImportFormatNDJSON
+}
+
+// ParseImport reads every row of an import file. Rows that can not be read are kept with an error so they show
+// up in the report; only a file that can not be read at all returns an error.
+func ParseImport(format string, reader io.Reader) ([]ImportRow, error) {
+	var rows []ImportRow
+	var err error
+	if format == ImportFormatCSV {
+		rows, err = parseImportCSV(reader)
+	} else {
+		rows, err = parseImportNDJSON(reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, invalid("Error: The import has no cars")
+	}
+	if len(rows) > maxImportRows {
+		return nil, invalid("Error: An import can have at most 5000 cars")
+	}
+	return rows, nil
+}
+
+func parseImportCSV(reader io.Reader) ([]ImportRow, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1 // Short rows are reported per row rather than failing the file
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, invalid("Error: The import has no cars")
+	}
+	if err != nil {
+		return nil, invalid("Error: Could not read the CSV header")
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !importColumns[header[i]] {
+			return nil, invalid("Error: Unknown CSV column " + column)
+		}
+	}
+
+	rows := []ImportRow{}
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, errImportUnreadable
+			}
+			rows = append(rows, ImportRow{Row: parseErr.StartLine, Error: "Error: Could not read the row"})
+			continue
+		}
+		line, _ := csvReader.FieldPos(0)
+		row := ImportRow{Row: line}
+		if len(record) != len(header) {
+			row.Error = "Error: The row has " + strconv.Itoa(len(record)) + " columns, the header has " + strconv.Itoa(len(header))
+			rows = append(rows, row)
+			continue
+		}
+
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "make":
+				row.Car.Make = value
+			case "model":
+				row.Car.Model = value
+			case "year":
+				if value == "" {
+					continue
+				}
+				year, err := strconv.Atoi(value)
+				if err != nil {
+					row.Error = "Error: Year must be a number"
+				}
+				row.Car.Year = year
+			case "vin":
+				row.Car.VIN = value
+			case "notes":
+				row.Car.Notes = value
+			case "tags":
+				for _, tag := range strings.Split(value, csvTagSeparator) {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						row.Car.Tags = append(row.Car.Tags, tag)
+					}
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportNDJSON(reader io.Reader) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(reader)
+	rows := []ImportRow{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := ImportRow{Row: line}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.Car); err != nil {
+			row.Error = "Error: Could not read the row as a car"
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		return nil, invalid("Error: Rows can be at most 64 KB")
+	} else if err != nil {
+		return nil, errImportUnreadable
+	}
+	return rows, nil
+}
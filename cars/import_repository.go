@@ -0,0 +1,73 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type ImportRepository struct {
+	db               *mongo.Database
+	importCollection string
+}
+
+func NewInstanceOfImportRepository(db *mongo.Database) ImportRepository {
+	return ImportRepository{db: db, importCollection: "carImports"}
+}
+
+func (r *ImportRepository) Save(job ImportJob) (primitive.ObjectID, error) {
+	insertResult, err := r.db.Collection(r.importCollection).InsertOne(context.TODO(), job)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID), nil
+}
+
+// Get finds an import started by the email.
+func (r *ImportRepository) Get(email string, jobID string) (ImportJob, error) {
+	docID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return ImportJob{}, ErrImportNotFound
+	}
+
+	var job ImportJob
+	err = r.db.Collection(r.importCollection).FindOne(context.TODO(), bson.M{"_id": docID, "email": email}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return ImportJob{}, ErrImportNotFound
+	}
+	if err != nil {
+		return ImportJob{}, err
+	}
+	return job, nil
+}
+
+// UpdateProgress saves the counts, errors and status of a running or finished import.
+func (r *ImportRepository) UpdateProgress(job ImportJob) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":        job.Status,
+			"processedRows": job.ProcessedRows,
+			"createdCount":  job.CreatedCount,
+			"failedCount":   job.FailedCount,
+			"errors":        job.Errors,
+			"finished":      job.Finished,
+		},
+	}
+	_, err := r.db.Collection(r.importCollection).UpdateOne(context.TODO(), bson.M{"_id": job.ID}, update)
+	return err
+}
+
+// FailRunning marks imports that are still running as failed. It is called on startup, when no import can be
+// running any more since they run in the server.
+func (r *ImportRepository) FailRunning() error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":   ImportFailed,
+			"finished": time.Now(),
+		},
+	}
+	_, err := r.db.Collection(r.importCollection).UpdateMany(context.TODO(), bson.M{"status": ImportRunning}, update)
+	return err
+}
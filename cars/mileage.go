@@ -0,0 +1,169 @@
+package cars
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Odometer reading sources. Service and fuel readings are taken from those records when the series is read,
+// only manual readings are stored as readings.
+const (
+	ReadingManual  = "manual"
+	ReadingService = "service"
+	ReadingFuel    = "fuel"
+)
+
+const (
+	// projectionWindow is how far back readings are used to project mileage, so it follows recent driving.
+	projectionWindow = 365 * 24 * time.Hour
+	// minProjectionSpan is the least time the readings must cover before a projection means anything.
+	minProjectionSpan = 14 * 24 * time.Hour
+)
+
+var ErrReadingNotFound = errors.New("Error: Odometer reading not found")
+
+type OdometerReading struct {
+	ID       primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CarID    string             `json:"carId" bson:"carId"`
+	Date     time.Time          `json:"date" bson:"date"`
+	Odometer int                `json:"odometer" bson:"odometer"`
+	Source   string             `json:"source" bson:"source"`
+	SourceID string             `json:"sourceId" bson:"-"` // The manual reading, service record or fuel log the reading came from
+	Email    string             `json:"email,omitempty" bson:"email"`
+	Created  time.Time          `json:"created" bson:"created"`
+}
+
+type CreateOdometerReading struct {
+	Date     time.Time `json:"date"`
+	Odometer int       `json:"odometer"`
+}
+
+func (c *CreateOdometerReading) Valid() error {
+	if c.Date.IsZero() {
+		return invalid("Error: Date is missing")
+	}
+	if c.Date.After(time.Now().Add(24 * time.Hour)) {
+		return invalid("Error: Date can not be in the future")
+	}
+	if c.Odometer <= 0 {
+		return invalid("Error: Odometer is missing")
+	}
+	return nil
+}
+
+// OdometerRollback is a reading lower than one taken before it. Either the reading is a typo or the odometer
+// was wound back.
+type OdometerRollback struct {
+	Reading  OdometerReading `json:"reading"`
+	Previous OdometerReading `json:"previous"` // The highest reading before it
+}
+
+type MileageProjection struct {
+	DailyAverage      float64   `json:"dailyAverage"`
+	AnnualMileage     int       `json:"annualMileage"`
+	ProjectedOdometer int       `json:"projectedOdometer"` // A year from now
+	ProjectedAt       time.Time `json:"projectedAt"`
+	BasedOnReadings   int       `json:"basedOnReadings"`
+}
+
+type Mileage struct {
+	Series     []OdometerReading  `json:"series"` // Oldest first
+	Latest     *OdometerReading   `json:"latest,omitempty"`
+	Rollbacks  []OdometerRollback `json:"rollbacks"`
+	Projection *MileageProjection `json:"projection,omitempty"` // Left out until there are enough readings
+}
+
+// ServiceReadings turns service records with an odometer into readings.
+func ServiceReadings(records []ServiceRecord) []OdometerReading {
+	readings := []OdometerReading{}
+	for _, record := range records {
+		if record.Odometer > 0 {
+			readings = append(readings, OdometerReading{CarID: record.CarID, Date: record.Date, Odometer: record.Odometer, Source: ReadingService, SourceID: record.ID.Hex(), Email: record.Email, Created: record.Created})
+		}
+	}
+	return readings
+}
+
+// FuelReadings turns fuel logs into readings.
+func FuelReadings(logs []FuelLog) []OdometerReading {
+	readings := []OdometerReading{}
+	for _, log := range logs {
+		readings = append(readings, OdometerReading{CarID: log.CarID, Date: log.Date, Odometer: log.Odometer, Source: ReadingFuel, SourceID: log.ID.Hex(), Email: log.Email, Created: log.Created})
+	}
+	return readings
+}
+
+// ComputeMileage orders the readings, finds rollbacks and projects the mileage from the readings that are not
+// rollbacks.
+func ComputeMileage(readings []OdometerReading, now time.Time) Mileage {
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].Date.Before(readings[j].Date)
+	})
+	mileage := Mileage{Series: readings, Rollbacks: []OdometerRollback{}}
+	if len(readings) == 0 {
+		return mileage
+	}
+
+	trusted := []OdometerReading{}
+	var highest *OdometerReading
+	for i := range readings {
+		if highest != nil && readings[i].Odometer < highest.Odometer {
+			mileage.Rollbacks = append(mileage.Rollbacks, OdometerRollback{Reading: readings[i], Previous: *highest})
+			continue
+		}
+		highest = &readings[i]
+		trusted = append(trusted, readings[i])
+	}
+	latest := trusted[len(trusted)-1]
+	mileage.Latest = &latest
+	mileage.Projection = projectMileage(trusted, now)
+	return mileage
+}
+
+// projectMileage fits a line through the recent readings (least squares on days against odometer) and uses its
+// slope as the daily average. Older readings are used when there are too few recent ones.
+func projectMileage(readings []OdometerReading, now time.Time) *MileageProjection {
+	recent := []OdometerReading{}
+	for _, reading := range readings {
+		if now.Sub(reading.Date) <= projectionWindow {
+			recent = append(recent, reading)
+		}
+	}
+	if len(recent) < 2 {
+		recent = readings
+	}
+	if len(recent) < 2 || recent[len(recent)-1].Date.Sub(recent[0].Date) < minProjectionSpan {
+		return nil
+	}
+
+	origin := recent[0].Date
+	n := float64(len(recent))
+	sumX, sumY, sumXY, sumXX := 0.0, 0.0, 0.0, 0.0
+	for _, reading := range recent {
+		x := reading.Date.Sub(origin).Hours() / 24
+		y := float64(reading.Odometer)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	if slope < 0 || math.IsNaN(slope) {
+		slope = 0
+	}
+
+	latest := readings[len(readings)-1]
+	projectedAt := now.AddDate(1, 0, 0)
+	daysAhead := projectedAt.Sub(latest.Date).Hours() / 24
+	return &MileageProjection{
+		DailyAverage:      math.Round(slope*10) / 10,
+		AnnualMileage:     int(math.Round(slope * 365)),
+		ProjectedOdometer: latest.Odometer + int(math.Round(slope*daysAhead)),
+		ProjectedAt:       projectedAt,
+		BasedOnReadings:   len(recent),
+	}
+}
@@ -0,0 +1,63 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MileageRepository struct {
+	db                *mongo.Database
+	readingCollection string
+}
+
+func NewInstanceOfMileageRepository(db *mongo.Database) MileageRepository {
+	return MileageRepository{db: db, readingCollection: "carOdometerReadings"}
+}
+
+// List returns the car's manual readings in no particular order.
+func (r *MileageRepository) List(carID string) ([]OdometerReading, error) {
+	cursor, err := r.db.Collection(r.readingCollection).Find(context.TODO(), bson.M{"carId": carID})
+	if err != nil {
+		return []OdometerReading{}, err
+	}
+	readings := []OdometerReading{}
+	if err := cursor.All(context.TODO(), &readings); err != nil {
+		return []OdometerReading{}, err
+	}
+	for i := range readings {
+		readings[i].SourceID = readings[i].ID.Hex()
+	}
+	return readings, nil
+}
+
+func (r *MileageRepository) Save(reading OdometerReading) (primitive.ObjectID, error) {
+	insertResult, err := r.db.Collection(r.readingCollection).InsertOne(context.TODO(), reading)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *MileageRepository) Delete(carID string, readingID string) error {
+	docID, err := primitive.ObjectIDFromHex(readingID)
+	if err != nil {
+		return ErrReadingNotFound
+	}
+
+	result, err := r.db.Collection(r.readingCollection).DeleteOne(context.TODO(), bson.M{"_id": docID, "carId": carID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrReadingNotFound
+	}
+	return nil
+}
+
+// DeleteForCar removes every reading of a car, used when the car is purged.
+func (r *MileageRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.readingCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
@@ -2,27 +2,166 @@ package cars
 
 import (
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type Car struct {
 	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Make    string             `json:"make",bson:"make"`
-	Model   string             `json:"model",bson:"model"`
-	Year    int                `json:"year",bson:"year"`
-	Status  string             `json:"status",bson:"status"`
-	Email   string             `json:"email",bson:"email"`
-	Created time.Time          `json:"created",bson:"created"`
+	Make    string             `json:"make" bson:"make"`
+	Model   string             `json:"model" bson:"model"`
+	Year    int                `json:"year" bson:"year"`
+	Status  string             `json:"status" bson:"status"`
+	Email   string             `json:"email" bson:"email"`
+	VIN     string             `json:"vin,omitempty" bson:"vin,omitempty"`
+	VINDetails *DecodedVIN     `json:"vinDetails,omitempty" bson:"vinDetails,omitempty"`
+	OwnerKey string            `json:"-" bson:"ownerKey,omitempty"` // See Owner.Key, used for the per owner VIN index
+	Notes   string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	Tags    []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Score   float64            `json:"score,omitempty" bson:"score,omitempty"` // Search relevance, only set on search results
+	OrganizationID string      `json:"organizationId,omitempty" bson:"organizationId,omitempty"` // Set when the car belongs to an organization, email is then who added it
+	OwnershipHistory []OwnershipRecord `json:"ownershipHistory,omitempty" bson:"ownershipHistory,omitempty"` // Previous owners, oldest first
+	StatusHistory []StatusChange `json:"-" bson:"statusHistory,omitempty"` // Served from its own endpoint, oldest first
+	PrimaryPhoto *CarPhoto     `json:"primaryPhoto,omitempty" bson:"primaryPhoto,omitempty"`
+	DeletedAt *time.Time       `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // Set while the car is in the trash
+	DeletedBy string           `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
+	Version int                `json:"version" bson:"version"` // Goes up with every change, see ETag
+	Created time.Time          `json:"created" bson:"created"`
 }
 
+func (c *Car) Description() string {
+	return fmt.Sprintf("%d %s %s", c.Year, c.Make, c.Model)
+}
+
+// OwnershipRecord is a previous owner of the car. It's stored on the car so the owner change and the history
+// are written in a single atomic update.
+type OwnershipRecord struct {
+	Email          string    `json:"email" bson:"email"`
+	OrganizationID string    `json:"organizationId,omitempty" bson:"organizationId,omitempty"`
+	TransferID     string    `json:"transferId" bson:"transferId"`
+	TransferredAt  time.Time `json:"transferredAt" bson:"transferredAt"`
+}
+
+var ErrCarNotFound = errors.New("Error: Car not found")
+var ErrNotPermitted = errors.New("Error: Not permitted")
+var ErrTransferNotFound = errors.New("Error: Transfer not found")
+var ErrTransferConflict = errors.New("Error: The car changed owner or was deleted after the transfer was requested")
+
+// ValidationError is a problem with the request that the client can fix, like a missing field or a value out of
+// range. It is shown to the client as a 400, see writeError.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// invalid makes a ValidationError, use it in place of errors.New for anything the client sent wrong.
+func invalid(message string) error {
+	return &ValidationError{Message: message}
+}
+
+// Owner is who a car belongs to. Either a single user by email or an organization.
+type Owner struct {
+	Email          string
+	OrganizationID string
+}
+
+func (c *Car) Owner() Owner {
+	return Owner{Email: c.Email, OrganizationID: c.OrganizationID}
+}
+
+func (o Owner) Filter() bson.M {
+	if o.OrganizationID != "" {
+		return bson.M{"organizationId": o.OrganizationID}
+	}
+	// Personal cars never have an organization
+	return bson.M{"email": o.Email, "organizationId": bson.M{"$exists": false}}
+}
+
+// Key is a single value naming the owner, so an index can be unique per owner.
+func (o Owner) Key() string {
+	if o.OrganizationID != "" {
+		return "organization:" + o.OrganizationID
+	}
+	return "user:" + o.Email
+}
+
+// maxFilterLength caps the free text filters so they stay cheap to match.
+const maxFilterLength = 100
+
 type ListCarQuery struct {
-	Page  int    `json:"page"`
+	Page  int    `json:"page"` // Only used for the older page/limit paging, zero means use the cursor
 	Limit int    `json:"limit"`
+	Cursor string `json:"cursor"`
+	IncludeTotal bool `json:"includeTotal"`
 	Make  string `json:"make"`
 	Model string `json:"model"`
-	Year  int    `json:"year"`
+	Search string `json:"search"` // Free text over make, model, notes and tags
+	YearMin int `json:"yearMin"` // Zero means no lower bound
+	YearMax int `json:"yearMax"` // Zero means no upper bound
+	Statuses []string `json:"statuses"`
+	CreatedFrom time.Time `json:"createdFrom"` // Zero means no lower bound
+	CreatedTo time.Time `json:"createdTo"` // Zero means no upper bound
+	Sort []SortField `json:"sort"` // Empty means newest first
+	OrganizationID string `json:"organizationId"`
+	Deleted bool `json:"deleted"` // Lists the cars in the trash instead
+}
+
+// Valid checks the paging and filters. Sort fields are checked against the whitelist here so nothing unknown
+// makes it into the bson.M.
+func (q *ListCarQuery) Valid() error {
+	if q.Page < 0 {
+		return invalid("Error: Page can not be negative, use 0 for cursor paging")
+	}
+	if q.Limit < 1 || q.Limit > 100 {
+		return invalid("Error: Limit must be between 1 and 100")
+	}
+	if q.Page > 0 && q.Cursor != "" {
+		return invalid("Error: Use either page or cursor, not both")
+	}
+	if len(q.Make) > maxFilterLength || len(q.Model) > maxFilterLength || len(q.Search) > maxFilterLength {
+		return invalid("Error: Filters can be at most 100 characters")
+	}
+	if q.YearMin < 0 || q.YearMax < 0 {
+		return invalid("Error: Year must be positive")
+	}
+	if q.YearMin != 0 && q.YearMax != 0 && q.YearMin > q.YearMax {
+		return invalid("Error: Year range must go from lowest to highest")
+	}
+	if !q.CreatedFrom.IsZero() && !q.CreatedTo.IsZero() && q.CreatedFrom.After(q.CreatedTo) {
+		return invalid("Error: Created range must go from earliest to latest")
+	}
+	for _, status := range q.Statuses {
+		if !IsValidStatus(status) {
+			return invalid("Error: Unknown status " + status)
+		}
+	}
+	for _, sortField := range q.Sort {
+		if !sortableFields[sortField.Field] {
+			return invalid("Error: Can not sort by " + sortField.Field)
+		}
+	}
+	return nil
+}
+
+// SortFields is the listing order, newest first (or most relevant first when searching) unless a sort was
+// asked for. The _id is always last so cars with the same values keep a stable order.
+func (q *ListCarQuery) SortFields() []SortField {
+	sortFields := []SortField{}
+	sortFields = append(sortFields, q.Sort...)
+	if len(sortFields) == 0 && q.Search != "" {
+		sortFields = append(sortFields, SortField{Field: "score", Descending: true})
+	}
+	if len(sortFields) == 0 {
+		sortFields = append(sortFields, SortField{Field: "created", Descending: true})
+	}
+	return append(sortFields, SortField{Field: "_id", Descending: true})
 }
 
 type ListCarQueryV1 struct {
@@ -33,11 +172,13 @@ type ListCarQueryV1 struct {
 	Year  int    `json:"year"`
 }
 
-func (q *ListCarQuery) Filter(email string) bson.M {
+func (q *ListCarQuery) Filter(owner Owner) bson.M {
 	andFilters := []bson.M{
-		bson.M{
-			"email": email,
-		},
+		owner.Filter(),
+		bson.M{"deletedAt": nil},
+	}
+	if q.Deleted {
+		andFilters[1] = bson.M{"deletedAt": bson.M{"$ne": nil}}
 	}
 
 	if q.Make != "" {
@@ -50,7 +191,7 @@ func (q *ListCarQuery) Filter(email string) bson.M {
 			bson.M{
 				"make": bson.M{
 					"$regex": primitive.Regex{
-						Pattern: "^" + q.Make + "*",
+						Pattern: "^" + regexp.QuoteMeta(q.Make),
 						Options: "i",
 					},
 				},
@@ -70,7 +211,7 @@ func (q *ListCarQuery) Filter(email string) bson.M {
 			bson.M{
 				"model": bson.M{
 					"$regex": primitive.Regex{
-						Pattern: "^" + q.Model + "*",
+						Pattern: "^" + regexp.QuoteMeta(q.Model),
 						Options: "i",
 					},
 				},
@@ -80,8 +221,38 @@ func (q *ListCarQuery) Filter(email string) bson.M {
 		andFilters = append(andFilters, bson.M{"$or": orFilters})
 	}
 
-	if q.Year != 0 {
-		andFilters = append(andFilters, bson.M{"year": q.Year})
+	if q.Search != "" {
+		andFilters = append(andFilters, bson.M{"$text": bson.M{"$search": q.Search}})
+	}
+
+	if q.YearMin != 0 || q.YearMax != 0 {
+		yearFilter := bson.M{}
+		if q.YearMin != 0 {
+			yearFilter["$gte"] = q.YearMin
+		}
+		if q.YearMax != 0 {
+			yearFilter["$lte"] = q.YearMax
+		}
+		andFilters = append(andFilters, bson.M{"year": yearFilter})
+	}
+
+	if len(q.Statuses) > 0 {
+		statuses := []string{}
+		for _, status := range q.Statuses {
+			statuses = append(statuses, storedStatuses(status)...)
+		}
+		andFilters = append(andFilters, bson.M{"status": bson.M{"$in": statuses}})
+	}
+
+	if !q.CreatedFrom.IsZero() || !q.CreatedTo.IsZero() {
+		createdFilter := bson.M{}
+		if !q.CreatedFrom.IsZero() {
+			createdFilter["$gte"] = q.CreatedFrom
+		}
+		if !q.CreatedTo.IsZero() {
+			createdFilter["$lte"] = q.CreatedTo
+		}
+		andFilters = append(andFilters, bson.M{"created": createdFilter})
 	}
 
 	if len(andFilters) == 0 {
@@ -95,6 +266,10 @@ type CreateCar struct {
 	Make  string `json:"make"`
 	Model string `json:"model"`
 	Year  int    `json:"year"`
+	VIN   string `json:"vin"` // Optional, fills in the make and year when they are left out
+	Notes string `json:"notes"`
+	Tags  []string `json:"tags"`
+	OrganizationID string `json:"organizationId"` // Optional, creates the car under the organization
 }
 
 type CreateCarV1 struct {
@@ -103,30 +278,112 @@ type CreateCarV1 struct {
 	Year  int    `json:"year"`
 }
 
+// Valid checks the car. When there is a VIN it is normalized and the make and year are filled in from it if
+// they are missing, or checked against it if they are not.
 func (c *CreateCar) Valid() error {
+	if c.VIN != "" {
+		c.VIN = NormalizeVIN(c.VIN)
+		if err := ValidateVIN(c.VIN); err != nil {
+			return err
+		}
+		decoded := DecodeVIN(c.VIN)
+		if c.Make == "" {
+			c.Make = decoded.Manufacturer
+		}
+		if c.Year == 0 {
+			c.Year = decoded.ModelYear
+		}
+		if err := decoded.Check(c.Make, c.Year); err != nil {
+			return err
+		}
+	}
 	if c.Make == "" {
-		return errors.New("Error: Make is missing")
+		return invalid("Error: Make is missing")
 	}
 	if c.Model == "" {
-		return errors.New("Error: Model is missing")
+		return invalid("Error: Model is missing")
 	}
 	if c.Year == 0 {
-		return errors.New("Error: Year is missing")
+		return invalid("Error: Year is missing")
+	}
+	return validTags(c.Tags)
+}
+
+// validTags keeps tags short and the list small enough to index.
+func validTags(tags []string) error {
+	if len(tags) > maxTags {
+		return invalid("Error: A car can have at most 20 tags")
+	}
+	for _, tag := range tags {
+		if tag == "" || len(tag) > maxFilterLength {
+			return invalid("Error: Tags must be between 1 and 100 characters")
+		}
 	}
 	return nil
 }
 
+const maxTags = 20
+
 type UpdateCar struct {
 	Make   string `json:"make"`
 	Model  string `json:"model"`
 	Year   int    `json:"year"`
 	Status string `json:"status"`
+	StatusReason string `json:"statusReason"` // Optional, kept in the status history
+	VIN    string `json:"vin"`
+	Notes  string `json:"notes"`
+	Tags   []string `json:"tags"` // Replaces all tags when set, an empty list clears them
+	Clear  []string `json:"-"`    // Fields to unset (vin or notes), used when reverting to a revision
 }
 
 func (u *UpdateCar) Valid() error {
-	return nil
+	if u.VIN != "" {
+		u.VIN = NormalizeVIN(u.VIN)
+		if err := ValidateVIN(u.VIN); err != nil {
+			return err
+		}
+	}
+	if u.Status != "" && !IsValidStatus(u.Status) {
+		return invalid("Error: Unknown status " + u.Status)
+	}
+	if u.StatusReason != "" && u.Status == "" {
+		return invalid("Error: A status reason needs a status")
+	}
+	if len(u.StatusReason) > maxStatusReasonLength {
+		return invalid("Error: Status reason can be at most 500 characters")
+	}
+	return validTags(u.Tags)
+}
+
+// Fields lists the names of the fields that will be changed.
+func (u *UpdateCar) Fields() []string {
+	fields := []string{}
+	if u.Make != "" {
+		fields = append(fields, "make")
+	}
+	if u.Model != "" {
+		fields = append(fields, "model")
+	}
+	if u.Year != 0 {
+		fields = append(fields, "year")
+	}
+	if u.Status != "" {
+		fields = append(fields, "status")
+	}
+	if u.VIN != "" {
+		fields = append(fields, "vin")
+	}
+	if u.Notes != "" {
+		fields = append(fields, "notes")
+	}
+	if u.Tags != nil {
+		fields = append(fields, "tags")
+	}
+	return append(fields, u.Clear...)
 }
 
+// Update is the change to the car's own fields. The status is left out since it is moved separately, see
+// Repository.Update.
 func (u *UpdateCar) Update() bson.M {
 	update := bson.M{}
 	if u.Make != "" {
@@ -138,13 +395,35 @@ func (u *UpdateCar) Update() bson.M {
 	if u.Year != 0 {
 		update["year"] = u.Year
 	}
-	if u.Status != "" {
-		update["status"] = u.Status
+	if u.VIN != "" {
+		decoded := DecodeVIN(u.VIN)
+		update["vin"] = u.VIN
+		update["vinDetails"] = &decoded
+	}
+	if u.Notes != "" {
+		update["notes"] = u.Notes
+	}
+	if u.Tags != nil {
+		update["tags"] = u.Tags
+	}
+	unset := bson.M{}
+	for _, field := range u.Clear {
+		unset[field] = ""
+		if field == "vin" {
+			unset["vinDetails"] = ""
+		}
 	}
-	if len(update) == 0 {
+	if len(update) == 0 && len(unset) == 0 {
 		return nil
 	}
-	return bson.M{"$set": update}
+	result := bson.M{}
+	if len(update) > 0 {
+		result["$set"] = update
+	}
+	if len(unset) > 0 {
+		result["$unset"] = unset
+	}
+	return result
 }
 
 type UpdateCarV1 struct {
@@ -153,3 +432,51 @@ type UpdateCarV1 struct {
 	Year   int    `json:"year"`
 	Status string `json:"status"`
 }
+
+// Transfer statuses. A pending transfer past its expiry can no longer be accepted or declined.
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+)
+
+type CarTransfer struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CarID     string             `json:"carId" bson:"carId"`
+	FromEmail string             `json:"fromEmail" bson:"fromEmail"`
+	ToEmail   string             `json:"toEmail" bson:"toEmail"`
+	Code      string             `json:"-" bson:"code"`
+	Status    string             `json:"status" bson:"status"`
+	Created   time.Time          `json:"created" bson:"created"`
+	Expiry    time.Time          `json:"expiry" bson:"expiry"`
+	Responded time.Time          `json:"responded,omitempty" bson:"responded,omitempty"`
+}
+
+type CreateTransfer struct {
+	Email string `json:"email"`
+}
+
+func (c *CreateTransfer) Valid() error {
+	if c.GetFormattedEmail() == "" {
+		return invalid("Error: Email is missing")
+	}
+	return nil
+}
+
+func (c *CreateTransfer) GetFormattedEmail() string {
+	email := strings.Trim(c.Email, " ")
+	email = strings.ToLower(email)
+	return email
+}
+
+type RespondTransfer struct {
+	Code string `json:"code"`
+}
+
+func (r *RespondTransfer) Valid() error {
+	if r.Code == "" {
+		return invalid("Error: Code is missing")
+	}
+	return nil
+}
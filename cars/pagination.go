@@ -0,0 +1,125 @@
+package cars
+
+import (
+	"encoding/base64"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+var ErrInvalidCursor = invalid("Error: Invalid cursor")
+
+// SortField is one field in the listing sort order. The _id is always added as the last field so the order is
+// stable when other values are equal.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// CarPage is a single page of a car listing. Cursors are empty when there is no page in that direction and
+// total is only set when it was asked for.
+type CarPage struct {
+	Cars       []Car
+	NextCursor string
+	PrevCursor string
+	Total      *int64
+}
+
+// pageCursor points at the last car of a page using the values of its sort fields. The values are encoded as
+// BSON so dates and object IDs keep their types, and the result is base64 so clients treat it as opaque.
+type pageCursor struct {
+	Values   bson.A `bson:"v"`
+	Previous bool   `bson:"p"`
+	Sort     string `bson:"s"` // The sort the cursor was made for, see sortSignature
+}
+
+func encodeCursor(car Car, sortFields []SortField, previous bool) (string, error) {
+	values := bson.A{}
+	for _, sortField := range sortFields {
+		values = append(values, carSortValue(car, sortField.Field))
+	}
+	data, err := bson.Marshal(pageCursor{Values: values, Previous: previous, Sort: sortSignature(sortFields)})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, sortFields []SortField) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	var decoded pageCursor
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	if len(decoded.Values) != len(sortFields) || decoded.Sort != sortSignature(sortFields) {
+		// The sort changed since the cursor was made
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return decoded, nil
+}
+
+// sortSignature writes the sort fields back in the "-year,make" form.
+func sortSignature(sortFields []SortField) string {
+	parts := []string{}
+	for _, sortField := range sortFields {
+		if sortField.Descending {
+			parts = append(parts, "-"+sortField.Field)
+		} else {
+			parts = append(parts, sortField.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// keysetFilter matches the cars after the cursor in the sort order (or before it when paging backwards).
+// For sort fields a, b, _id that is: a past v0, or a equal and b past v1, or a and b equal and _id past v2.
+func keysetFilter(cursor pageCursor, sortFields []SortField) bson.M {
+	orFilters := []bson.M{}
+	for i, sortField := range sortFields {
+		filter := bson.M{}
+		for j := 0; j < i; j++ {
+			filter[sortFields[j].Field] = cursor.Values[j]
+		}
+		operator := "$gt"
+		if sortField.Descending != cursor.Previous {
+			operator = "$lt"
+		}
+		filter[sortField.Field] = bson.M{operator: cursor.Values[i]}
+		orFilters = append(orFilters, filter)
+	}
+	return bson.M{"$or": orFilters}
+}
+
+// sortDocument converts the sort fields to a Mongo sort, flipping every direction when paging backwards.
+func sortDocument(sortFields []SortField, reverse bool) bson.D {
+	sort := bson.D{}
+	for _, sortField := range sortFields {
+		direction := 1
+		if sortField.Descending != reverse {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: sortField.Field, Value: direction})
+	}
+	return sort
+}
+
+func carSortValue(car Car, field string) interface{} {
+	switch field {
+	case "_id":
+		return car.ID
+	case "make":
+		return car.Make
+	case "model":
+		return car.Model
+	case "year":
+		return car.Year
+	case "status":
+		return car.Status
+	case "score":
+		return car.Score
+	default:
+		return car.Created
+	}
+}
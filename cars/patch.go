@@ -0,0 +1,377 @@
+package cars
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch content types, see Services.Patch.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+	MaxPatchBytes         = 1 << 20
+)
+
+var ErrUnsupportedPatch = errors.New("Error: Patches must be application/merge-patch+json or application/json-patch+json")
+var errPathNotFound = errors.New("path not found")
+
+// PatchOperation is one operation of a JSON Patch (RFC 6902). Value is kept raw so a missing value can be told
+// apart from null.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// patchedCar is the car as a patch sees it. The status reason is never part of the car, it can be added by the
+// patch to go in the status history.
+type patchedCar struct {
+	CarSnapshot
+	StatusReason string `json:"statusReason"`
+}
+
+// patchCar applies the patch to the snapshot and returns the update that gets the car there. Removing or nulling
+// the vin, notes or tags clears them, and doing so to the status sets the car back to active.
+func patchCar(current CarSnapshot, contentType string, patch []byte) (UpdateCar, error) {
+	var document interface{}
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return UpdateCar{}, err
+	}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return UpdateCar{}, err
+	}
+
+	switch contentType {
+	case MergePatchContentType:
+		var mergePatch interface{}
+		if err := json.Unmarshal(patch, &mergePatch); err != nil {
+			return UpdateCar{}, invalid("Error: The patch is not valid JSON")
+		}
+		document = applyMergePatch(document, mergePatch)
+	case JSONPatchContentType:
+		var operations []PatchOperation
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return UpdateCar{}, invalid("Error: A JSON Patch must be a list of operations")
+		}
+		document, err = applyJSONPatch(document, operations)
+		if err != nil {
+			return UpdateCar{}, err
+		}
+	default:
+		return UpdateCar{}, ErrUnsupportedPatch
+	}
+
+	target, err := decodePatchedCar(document)
+	if err != nil {
+		return UpdateCar{}, err
+	}
+	if target.Make == "" {
+		return UpdateCar{}, invalid("Error: Make is missing")
+	}
+	if target.Model == "" {
+		return UpdateCar{}, invalid("Error: Model is missing")
+	}
+	if target.Year == 0 {
+		return UpdateCar{}, invalid("Error: Year is missing")
+	}
+	if target.Status == "" {
+		target.Status = StatusActive
+	}
+
+	body := updateTo(current, target.CarSnapshot)
+	body.StatusReason = target.StatusReason
+	if err := body.Valid(); err != nil {
+		return UpdateCar{}, err
+	}
+	return body, nil
+}
+
+// decodePatchedCar checks the patched document still has the shape of a car.
+func decodePatchedCar(document interface{}) (patchedCar, error) {
+	if _, ok := document.(map[string]interface{}); !ok {
+		return patchedCar{}, invalid("Error: The patched car must be an object")
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return patchedCar{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	var target patchedCar
+	if err := decoder.Decode(&target); err != nil {
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) {
+			return patchedCar{}, invalid("Error: " + typeError.Field + " must be " + jsonTypeName(typeError.Type))
+		}
+		// Only unknown fields are left, the document was encoded just above
+		return patchedCar{}, invalid("Error: Only make, model, year, status, statusReason, vin, notes and tags can be patched")
+	}
+	return target, nil
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int:
+		return "a whole number"
+	case reflect.Slice:
+		return "a list of strings"
+	}
+	return "a string"
+}
+
+// applyMergePatch merges the patch into the target as RFC 7396 describes. Nulls remove members and anything that
+// is not an object replaces the target as a whole.
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = applyMergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// applyJSONPatch runs the operations in order as RFC 6902 describes. If any of them fails the patch as a whole
+// fails.
+func applyJSONPatch(document interface{}, operations []PatchOperation) (interface{}, error) {
+	for i, operation := range operations {
+		var err error
+		document, err = operation.apply(document)
+		if err != nil {
+			return nil, invalid("Error: Patch operation " + strconv.Itoa(i) + " (" + operation.Op + " " + operation.Path + ") failed, " + err.Error())
+		}
+	}
+	return document, nil
+}
+
+func (o *PatchOperation) apply(document interface{}) (interface{}, error) {
+	path, err := parsePointer(o.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		if len(o.Value) == 0 {
+			return nil, errors.New("value is missing")
+		}
+		var value interface{}
+		if err := json.Unmarshal(o.Value, &value); err != nil {
+			return nil, errors.New("value is not valid JSON")
+		}
+		switch o.Op {
+		case "add":
+			return addValue(document, path, value)
+		case "replace":
+			if document, _, err = removeValue(document, path); err != nil {
+				return nil, err
+			}
+			return addValue(document, path, value)
+		default:
+			current, err := getValue(document, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, errors.New("value does not match")
+			}
+			return document, nil
+		}
+	case "remove":
+		document, _, err = removeValue(document, path)
+		return document, err
+	case "move", "copy":
+		from, err := parsePointer(o.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		if o.Op == "copy" {
+			return addValue(document, path, copyValue(value))
+		}
+		if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+			return nil, errors.New("a value can not be moved into itself")
+		}
+		if document, _, err = removeValue(document, from); err != nil {
+			return nil, err
+		}
+		return addValue(document, path, value)
+	}
+	return nil, errors.New("op must be add, remove, replace, move, copy or test")
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens. The empty pointer is the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New("paths must start with /")
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex reads an array index, which can be at most max. Leading zeros are not allowed.
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || strings.HasPrefix(token, "+") || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.New("array index " + token + " is not valid")
+	}
+	if index > max {
+		return 0, errPathNotFound
+	}
+	return index, nil
+}
+
+func getValue(document interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := document.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, errPathNotFound
+			}
+			document = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			document = node[index]
+		default:
+			return nil, errPathNotFound
+		}
+	}
+	return document, nil
+}
+
+// addValue sets an object member or inserts into an array, with "-" adding to the end. Arrays are rebuilt so the
+// changed document is returned.
+func addValue(document interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token := path[0]
+	switch node := document.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, errPathNotFound
+		}
+		child, err := addValue(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []interface{}:
+		if len(path) == 1 {
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		index, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		if node[index], err = addValue(node[index], path[1:], value); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return nil, errPathNotFound
+}
+
+// removeValue removes the value at the path and returns the changed document along with the value removed.
+func removeValue(document interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, document, nil
+	}
+	token := path[0]
+	switch node := document.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, errPathNotFound
+		}
+		if len(path) == 1 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := removeValue(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := node[index]
+			return append(node[:index], node[index+1:]...), removed, nil
+		}
+		child, removed, err := removeValue(node[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[index] = child
+		return node, removed, nil
+	}
+	return nil, nil, errPathNotFound
+}
+
+// copyValue deep copies a decoded JSON value so a copied object or array does not share its members.
+func copyValue(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for key, member := range node {
+			copied[key] = copyValue(member)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for i, member := range node {
+			copied[i] = copyValue(member)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,294 @@
+package cars
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, document string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		t.Fatalf("could not decode %s: %v", document, err)
+	}
+	return value
+}
+
+// The examples from RFC 7396 Appendix A.
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		got := applyMergePatch(decodeJSON(t, test.target), decodeJSON(t, test.patch))
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("merging %s into %s gave %v, want %v", test.patch, test.target, got, want)
+		}
+	}
+}
+
+// The examples from RFC 6902 Appendix A, in order. A want of "" means the patch has to fail.
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		patch    string
+		want     string
+	}{
+		{
+			"adding an object member",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			"adding an array element",
+			`{"foo":["bar","baz"]}`,
+			`[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			`{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			"removing an object member",
+			`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"remove","path":"/baz"}]`,
+			`{"foo":"bar"}`,
+		},
+		{
+			"removing an array element",
+			`{"foo":["bar","qux","baz"]}`,
+			`[{"op":"remove","path":"/foo/1"}]`,
+			`{"foo":["bar","baz"]}`,
+		},
+		{
+			"replacing a value",
+			`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`,
+			`{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			"moving a value",
+			`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			"moving an array element",
+			`{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			"testing a value, success",
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			"testing a value, error",
+			`{"baz":"qux"}`,
+			`[{"op":"test","path":"/baz","value":"bar"}]`,
+			``,
+		},
+		{
+			"adding a nested member object",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			"ignoring unrecognized elements",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			`{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			"adding to a nonexistent target",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			``,
+		},
+		{
+			"invalid JSON Patch document",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux","op":"remove"}]`,
+			``,
+		},
+		{
+			"~ escape ordering",
+			`{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":10}]`,
+			`{"/":9,"~1":10}`,
+		},
+		{
+			"comparing strings and numbers",
+			`{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":"10"}]`,
+			``,
+		},
+		{
+			"adding an array value",
+			`{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`,
+		},
+	}
+	for _, test := range tests {
+		var operations []PatchOperation
+		if err := json.Unmarshal([]byte(test.patch), &operations); err != nil {
+			t.Fatalf("%s: could not decode the patch: %v", test.name, err)
+		}
+		got, err := applyJSONPatch(decodeJSON(t, test.document), operations)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+	}
+}
+
+func TestPatchCar(t *testing.T) {
+	current := CarSnapshot{
+		Make:   "Toyota",
+		Model:  "Corolla",
+		Year:   2018,
+		Status: StatusForSale,
+		VIN:    "2T1BURHE5JC034181",
+		Notes:  "Winter tyres in the garage",
+		Tags:   []string{"family"},
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        UpdateCar
+		wantErr     string
+	}{
+		{
+			name:        "merge patch changing the model",
+			contentType: MergePatchContentType,
+			patch:       `{"model":"Camry"}`,
+			want:        UpdateCar{Model: "Camry"},
+		},
+		{
+			name:        "merge patch nulling the status",
+			contentType: MergePatchContentType,
+			patch:       `{"status":null}`,
+			want:        UpdateCar{Status: StatusActive},
+		},
+		{
+			name:        "merge patch nulling the vin",
+			contentType: MergePatchContentType,
+			patch:       `{"vin":null}`,
+			want:        UpdateCar{Clear: []string{"vin"}},
+		},
+		{
+			name:        "merge patch nulling the tags",
+			contentType: MergePatchContentType,
+			patch:       `{"tags":null}`,
+			want:        UpdateCar{Tags: []string{}},
+		},
+		{
+			name:        "merge patch with a status reason",
+			contentType: MergePatchContentType,
+			patch:       `{"status":"sold","statusReason":"Sold to a neighbour"}`,
+			want:        UpdateCar{Status: StatusSold, StatusReason: "Sold to a neighbour"},
+		},
+		{
+			name:        "merge patch with a year that is not a whole number",
+			contentType: MergePatchContentType,
+			patch:       `{"year":2018.5}`,
+			wantErr:     "Error: year must be a whole number",
+		},
+		{
+			name:        "merge patch nulling the make",
+			contentType: MergePatchContentType,
+			patch:       `{"make":null}`,
+			wantErr:     "Error: Make is missing",
+		},
+		{
+			name:        "merge patch with an unknown field",
+			contentType: MergePatchContentType,
+			patch:       `{"owner":"someone@example.com"}`,
+			wantErr:     "Error: Only make, model, year, status, statusReason, vin, notes and tags can be patched",
+		},
+		{
+			name:        "merge patch replacing the whole car",
+			contentType: MergePatchContentType,
+			patch:       `["Toyota"]`,
+			wantErr:     "Error: The patched car must be an object",
+		},
+		{
+			name:        "JSON patch removing the status",
+			contentType: JSONPatchContentType,
+			patch:       `[{"op":"remove","path":"/status"}]`,
+			want:        UpdateCar{Status: StatusActive},
+		},
+		{
+			name:        "JSON patch removing the vin and adding a tag",
+			contentType: JSONPatchContentType,
+			patch:       `[{"op":"remove","path":"/vin"},{"op":"add","path":"/tags/-","value":"weekend"}]`,
+			want:        UpdateCar{Tags: []string{"family", "weekend"}, Clear: []string{"vin"}},
+		},
+		{
+			name:        "JSON patch with a failing test",
+			contentType: JSONPatchContentType,
+			patch:       `[{"op":"test","path":"/model","value":"Camry"},{"op":"replace","path":"/model","value":"Yaris"}]`,
+			wantErr:     "Error: Patch operation 0 (test /model) failed, value does not match",
+		},
+		{
+			name:        "JSON patch that is not a list",
+			contentType: JSONPatchContentType,
+			patch:       `{"op":"remove","path":"/vin"}`,
+			wantErr:     "Error: A JSON Patch must be a list of operations",
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json",
+			patch:       `{"model":"Camry"}`,
+			wantErr:     ErrUnsupportedPatch.Error(),
+		},
+	}
+	for _, test := range tests {
+		got, err := patchCar(current, test.contentType, []byte(test.patch))
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cars
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Photo variants, from the largest down.
+const (
+	PhotoFull      = "full"
+	PhotoLarge     = "large"
+	PhotoThumbnail = "thumbnail"
+)
+
+const (
+	MaxPhotoBytes = 15 << 20
+	maxCarPhotos  = 50
+)
+
+var ErrPhotoNotFound = errors.New("Error: Photo not found")
+var ErrPhotoTooLarge = errors.New("Error: Photos can be at most 15 MB")
+var ErrTooManyPhotos = errors.New("Error: Cars can have at most 50 photos")
+
+type Photo struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CarID       string             `json:"carId" bson:"carId"`
+	ContentType string             `json:"contentType" bson:"contentType"` // The same for every variant
+	Variants    []PhotoVariant     `json:"variants" bson:"variants"`
+	Primary     bool               `json:"primary" bson:"primary"`
+	Email       string             `json:"email" bson:"email"`
+	Created     time.Time          `json:"created" bson:"created"`
+}
+
+type PhotoVariant struct {
+	Name   string `json:"name" bson:"name"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+	Size   int64  `json:"size" bson:"size"`
+	URL    string `json:"url" bson:"-"` // Set when the photo is read
+}
+
+// CarPhoto is the car's primary photo, kept on the car so listings can show it without looking up photos.
+type CarPhoto struct {
+	ID           string `json:"id" bson:"id"`
+	ThumbnailURL string `json:"thumbnailUrl" bson:"thumbnailUrl"`
+}
+
+func (p *Photo) Variant(name string) (PhotoVariant, bool) {
+	for _, variant := range p.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return PhotoVariant{}, false
+}
+
+// setURLs points each variant at its download.
+func (p *Photo) setURLs() {
+	for i := range p.Variants {
+		p.Variants[i].URL = photoURL(p.CarID, p.ID, p.Variants[i].Name)
+	}
+}
+
+func (p *Photo) carPhoto() CarPhoto {
+	return CarPhoto{ID: p.ID.Hex(), ThumbnailURL: photoURL(p.CarID, p.ID, PhotoThumbnail)}
+}
+
+func photoURL(carID string, photoID primitive.ObjectID, variant string) string {
+	return "/cars/" + carID + "/photos/" + photoID.Hex() + "/" + variant
+}
+
+// photoStorageKey is where a variant of the photo is kept.
+func photoStorageKey(carID string, photoID primitive.ObjectID, variant string) string {
+	return "cars/" + carID + "/photos/" + photoID.Hex() + "/" + variant
+}
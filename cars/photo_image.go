@@ -0,0 +1,230 @@
+package cars
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// maxPhotoPixels stops small files that decode into huge images from using up memory. A decoded photo takes
+	// 4 bytes a pixel, about 100 MB at the limit, plus the copy it was decoded into.
+	maxPhotoPixels = 24000000
+	// maxPhotosProcessing caps how many photos are decoded at once so a burst of uploads can't add up to more
+	// memory than the server has.
+	maxPhotosProcessing = 2
+	jpegQuality         = 85
+)
+
+// photoProcessing holds a slot for every photo being processed, see maxPhotosProcessing.
+var photoProcessing = make(chan struct{}, maxPhotosProcessing)
+
+// photoVariantSizes are the variants made of every photo and the longest side each is scaled down to. Photos
+// smaller than a variant are never scaled up.
+var photoVariantSizes = []struct {
+	Name    string
+	MaxSide int
+}{
+	{PhotoFull, 2560},
+	{PhotoLarge, 1280},
+	{PhotoThumbnail, 320},
+}
+
+// EncodedVariant is a variant ready to be stored.
+type EncodedVariant struct {
+	PhotoVariant
+	Data []byte
+}
+
+// ProcessPhoto decodes a JPEG or PNG, turns it upright using its EXIF orientation and encodes each variant in the
+// same format. The variants are encoded from the decoded pixels alone, so EXIF data like the GPS position the
+// photo was taken at is left behind.
+func ProcessPhoto(data []byte) (string, []EncodedVariant, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return "", nil, invalid("Error: Photos must be a JPEG or PNG")
+	}
+	if config.Width*config.Height > maxPhotoPixels {
+		return "", nil, invalid("Error: Photos can be at most 24 megapixels")
+	}
+
+	photoProcessing <- struct{}{}
+	defer func() { <-photoProcessing }()
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, invalid("Error: Photo could not be read")
+	}
+	// Scaling to the largest variant first means orienting and the smaller variants work on at most 2560 pixels
+	// a side
+	img := scaleDown(toRGBA(decoded), photoVariantSizes[0].MaxSide)
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	contentType := "image/" + format
+	variants := []EncodedVariant{}
+	for _, size := range photoVariantSizes {
+		scaled := scaleDown(img, size.MaxSide)
+		var buffer bytes.Buffer
+		if format == "jpeg" {
+			err = jpeg.Encode(&buffer, scaled, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			err = png.Encode(&buffer, scaled)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		bounds := scaled.Bounds()
+		variants = append(variants, EncodedVariant{
+			PhotoVariant: PhotoVariant{Name: size.Name, Width: bounds.Dx(), Height: bounds.Dy(), Size: int64(buffer.Len())},
+			Data:         buffer.Bytes(),
+		})
+	}
+	return contentType, variants, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// scaleDown fits the image inside a square of maxSide, averaging the source pixels behind each new pixel. Images
+// already small enough are returned as they are.
+func scaleDown(img *image.RGBA, maxSide int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+	newWidth, newHeight := maxSide, height*maxSide/width
+	if height > width {
+		newWidth, newHeight = width*maxSide/height, maxSide
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		fromY, toY := y*height/newHeight, (y+1)*height/newHeight
+		if toY == fromY {
+			toY++
+		}
+		for x := 0; x < newWidth; x++ {
+			fromX, toX := x*width/newWidth, (x+1)*width/newWidth
+			if toX == fromX {
+				toX++
+			}
+			var r, g, b, a, count int
+			for sy := fromY; sy < toY; sy++ {
+				offset := img.PixOffset(fromX, sy)
+				for sx := fromX; sx < toX; sx++ {
+					r += int(img.Pix[offset])
+					g += int(img.Pix[offset+1])
+					b += int(img.Pix[offset+2])
+					a += int(img.Pix[offset+3])
+					offset += 4
+				}
+				count += toX - fromX
+			}
+			offset := scaled.PixOffset(x, y)
+			scaled.Pix[offset] = uint8(r / count)
+			scaled.Pix[offset+1] = uint8(g / count)
+			scaled.Pix[offset+2] = uint8(b / count)
+			scaled.Pix[offset+3] = uint8(a / count)
+		}
+	}
+	return scaled
+}
+
+// orient turns the image the way the EXIF orientation (1 to 8) says it should be shown.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	// Orientations 5 to 8 are turned on their side
+	newWidth, newHeight := width, height
+	if orientation >= 5 {
+		newWidth, newHeight = height, width
+	}
+
+	oriented := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var nx, ny int
+			switch orientation {
+			case 2: // Mirrored
+				nx, ny = width-1-x, y
+			case 3: // Upside down
+				nx, ny = width-1-x, height-1-y
+			case 4: // Upside down and mirrored
+				nx, ny = x, height-1-y
+			case 5: // Mirrored and turned left
+				nx, ny = y, x
+			case 6: // Turned left, needs turning right
+				nx, ny = height-1-y, x
+			case 7: // Mirrored and turned right
+				nx, ny = height-1-y, width-1-x
+			case 8: // Turned right, needs turning left
+				nx, ny = y, width-1-x
+			}
+			copy(oriented.Pix[oriented.PixOffset(nx, ny):oriented.PixOffset(nx, ny)+4], img.Pix[img.PixOffset(x, y):img.PixOffset(x, y)+4])
+		}
+	}
+	return oriented
+}
+
+// exifOrientation reads the orientation tag from a JPEG's EXIF data. It returns 1, upright, when there is none or
+// the data can't be read.
+func exifOrientation(data []byte) int {
+	const orientationTag = 0x0112
+	// Walk the segments up to the image data looking for the EXIF one (APP1)
+	for offset := 2; offset+4 <= len(data) && data[offset] == 0xFF; {
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == 0xDA || length < 2 || offset+2+length > len(data) {
+			break
+		}
+		segment := data[offset+4 : offset+2+length]
+		offset += 2 + length
+		if marker != 0xE1 || len(segment) < 14 || string(segment[:6]) != "Exif\x00\x00" {
+			continue
+		}
+
+		tiff := segment[6:]
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		entries := int(order.Uint16(tiff[ifd:]))
+		for i := 0; i < entries; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) == orientationTag {
+				return int(order.Uint16(tiff[entry+8:]))
+			}
+		}
+		return 1
+	}
+	return 1
+}
@@ -0,0 +1,100 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PhotoRepository struct {
+	db              *mongo.Database
+	photoCollection string
+}
+
+func NewInstanceOfPhotoRepository(db *mongo.Database) PhotoRepository {
+	return PhotoRepository{db: db, photoCollection: "carPhotos"}
+}
+
+// CreateIndexes sets up the index used to list a car's photos. It is safe to call on every start.
+func (r *PhotoRepository) CreateIndexes() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "carId", Value: 1}, {Key: "created", Value: 1}},
+		Options: options.Index().SetName("photosCar"),
+	}
+	_, err := r.db.Collection(r.photoCollection).Indexes().CreateOne(context.TODO(), index)
+	return err
+}
+
+// List returns the car's photos, oldest first.
+func (r *PhotoRepository) List(carID string) ([]Photo, error) {
+	options := options.Find().SetSort(bson.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.db.Collection(r.photoCollection).Find(context.TODO(), bson.M{"carId": carID}, options)
+	if err != nil {
+		return []Photo{}, err
+	}
+	photos := []Photo{}
+	if err := cursor.All(context.TODO(), &photos); err != nil {
+		return []Photo{}, err
+	}
+	return photos, nil
+}
+
+func (r *PhotoRepository) Count(carID string) (int64, error) {
+	return r.db.Collection(r.photoCollection).CountDocuments(context.TODO(), bson.M{"carId": carID})
+}
+
+func (r *PhotoRepository) Get(carID string, photoID string) (Photo, error) {
+	docID, err := primitive.ObjectIDFromHex(photoID)
+	if err != nil {
+		return Photo{}, ErrPhotoNotFound
+	}
+
+	var photo Photo
+	err = r.db.Collection(r.photoCollection).FindOne(context.TODO(), bson.M{"_id": docID, "carId": carID}).Decode(&photo)
+	if err == mongo.ErrNoDocuments {
+		return Photo{}, ErrPhotoNotFound
+	}
+	if err != nil {
+		return Photo{}, err
+	}
+	return photo, nil
+}
+
+// Save stores the photo under its ID, which is picked beforehand so the variants can be stored first.
+func (r *PhotoRepository) Save(photo Photo) error {
+	_, err := r.db.Collection(r.photoCollection).InsertOne(context.TODO(), photo)
+	return err
+}
+
+// SetPrimary makes the photo the car's only primary photo.
+func (r *PhotoRepository) SetPrimary(carID string, photoID primitive.ObjectID) error {
+	result, err := r.db.Collection(r.photoCollection).UpdateOne(context.TODO(), bson.M{"_id": photoID, "carId": carID}, bson.M{"$set": bson.M{"primary": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPhotoNotFound
+	}
+	filter := bson.M{"carId": carID, "_id": bson.M{"$ne": photoID}, "primary": true}
+	_, err = r.db.Collection(r.photoCollection).UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"primary": false}})
+	return err
+}
+
+func (r *PhotoRepository) Delete(carID string, photoID primitive.ObjectID) error {
+	result, err := r.db.Collection(r.photoCollection).DeleteOne(context.TODO(), bson.M{"_id": photoID, "carId": carID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrPhotoNotFound
+	}
+	return nil
+}
+
+// DeleteForCar removes every photo of a car, used when the car is purged. The files are removed separately.
+func (r *PhotoRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.photoCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
@@ -0,0 +1,172 @@
+package cars
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reminder states. A reminder with an interval stays active and moves on to its next due point when completed,
+// one without is completed for good.
+const (
+	ReminderActive    = "active"
+	ReminderCompleted = "completed"
+)
+
+const (
+	maxReminderTitleLength = 100
+	maxIntervalMonths      = 120
+	// maxSnooze keeps snoozed reminders from being forgotten about.
+	maxSnooze = 365 * 24 * time.Hour
+)
+
+var ErrReminderNotFound = errors.New("Error: Reminder not found")
+var ErrReminderCompleted = errors.New("Error: Reminder is already completed")
+
+// Reminder is maintenance due on a date, at an odometer reading or both, whichever comes first. The intervals
+// work out the next due point each time it is completed, like an oil change every 8000 km or 6 months.
+type Reminder struct {
+	ID             primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	CarID          string              `json:"carId" bson:"carId"`
+	Title          string              `json:"title" bson:"title"`
+	IntervalMonths int                 `json:"intervalMonths,omitempty" bson:"intervalMonths,omitempty"`
+	IntervalKm     int                 `json:"intervalKm,omitempty" bson:"intervalKm,omitempty"`
+	DueDate        *time.Time          `json:"dueDate,omitempty" bson:"dueDate,omitempty"`
+	DueOdometer    int                 `json:"dueOdometer,omitempty" bson:"dueOdometer,omitempty"`
+	Status         string              `json:"status" bson:"status"`
+	SnoozedUntil   *time.Time          `json:"snoozedUntil,omitempty" bson:"snoozedUntil,omitempty"`
+	LastCompleted  *ReminderCompletion `json:"lastCompleted,omitempty" bson:"lastCompleted,omitempty"`
+	DocumentID     string              `json:"documentId,omitempty" bson:"documentId,omitempty"` // Set on renewal reminders for a document's expiry
+	NotifiedWindow string              `json:"-" bson:"notifiedWindow,omitempty"`                // The due window the last email was sent for, see Window
+	NotifiedAt     *time.Time          `json:"notifiedAt,omitempty" bson:"notifiedAt,omitempty"`
+	Due            bool                `json:"due" bson:"-"`       // Worked out when the reminders are read
+	Email          string              `json:"email" bson:"email"` // Who added the reminder, emailed for organization cars
+	Created        time.Time           `json:"created" bson:"created"`
+}
+
+type ReminderCompletion struct {
+	Date     time.Time `json:"date" bson:"date"`
+	Odometer int       `json:"odometer,omitempty" bson:"odometer,omitempty"`
+	Email    string    `json:"email" bson:"email"`
+}
+
+// IsDue is true once the due date or due odometer is reached. Snoozing does not change whether it is due, only
+// whether it is sent.
+func (r *Reminder) IsDue(now time.Time, odometer int) bool {
+	if r.Status != ReminderActive {
+		return false
+	}
+	if r.DueDate != nil && !now.Before(*r.DueDate) {
+		return true
+	}
+	return r.DueOdometer > 0 && odometer >= r.DueOdometer
+}
+
+func (r *Reminder) IsSnoozed(now time.Time) bool {
+	return r.SnoozedUntil != nil && now.Before(*r.SnoozedUntil)
+}
+
+// Window names the due point and snooze the reminder is on. An email is sent once per window, so completing or
+// snoozing a reminder allows another email once it is due again.
+func (r *Reminder) Window() string {
+	parts := []string{"", strconv.Itoa(r.DueOdometer), ""}
+	if r.DueDate != nil {
+		parts[0] = r.DueDate.UTC().Format(time.RFC3339)
+	}
+	if r.SnoozedUntil != nil {
+		parts[2] = r.SnoozedUntil.UTC().Format(time.RFC3339)
+	}
+	return strings.Join(parts, "|")
+}
+
+// DueDescription is when the reminder is due, for emails.
+func (r *Reminder) DueDescription() string {
+	date := ""
+	if r.DueDate != nil {
+		date = "on " + r.DueDate.Format("January 2, 2006")
+	}
+	odometer := ""
+	if r.DueOdometer > 0 {
+		odometer = "at " + strconv.Itoa(r.DueOdometer) + " km"
+	}
+	if date != "" && odometer != "" {
+		return date + " or " + odometer + ", whichever comes first"
+	}
+	return date + odometer
+}
+
+// scheduleFrom sets the next due point from a date and odometer reading using the intervals. Due points without
+// an interval are left alone.
+func (r *Reminder) scheduleFrom(date time.Time, odometer int) {
+	if r.IntervalMonths > 0 {
+		dueDate := date.AddDate(0, r.IntervalMonths, 0)
+		r.DueDate = &dueDate
+	}
+	if r.IntervalKm > 0 {
+		r.DueOdometer = odometer + r.IntervalKm
+	}
+}
+
+// CreateReminder needs a due date, due odometer or an interval. Intervals without a due point start counting from
+// now and the car's latest odometer reading.
+type CreateReminder struct {
+	Title          string     `json:"title"`
+	DueDate        *time.Time `json:"dueDate"`
+	DueOdometer    int        `json:"dueOdometer"`
+	IntervalMonths int        `json:"intervalMonths"`
+	IntervalKm     int        `json:"intervalKm"`
+}
+
+func (c *CreateReminder) Valid() error {
+	c.Title = strings.TrimSpace(c.Title)
+	if c.Title == "" {
+		return invalid("Error: Title is missing")
+	}
+	if len(c.Title) > maxReminderTitleLength {
+		return invalid("Error: Title can be at most " + strconv.Itoa(maxReminderTitleLength) + " characters")
+	}
+	if c.DueOdometer < 0 || c.IntervalKm < 0 || c.IntervalMonths < 0 {
+		return invalid("Error: Odometer and intervals can not be negative")
+	}
+	if c.IntervalMonths > maxIntervalMonths {
+		return invalid("Error: Interval can be at most " + strconv.Itoa(maxIntervalMonths) + " months")
+	}
+	if c.DueDate == nil && c.DueOdometer == 0 && c.IntervalMonths == 0 && c.IntervalKm == 0 {
+		return invalid("Error: A due date, due odometer or interval is needed")
+	}
+	return nil
+}
+
+type SnoozeReminder struct {
+	Until time.Time `json:"until"`
+}
+
+func (s *SnoozeReminder) Valid() error {
+	now := time.Now()
+	if !s.Until.After(now) {
+		return invalid("Error: Until must be in the future")
+	}
+	if s.Until.After(now.Add(maxSnooze)) {
+		return invalid("Error: Reminders can be snoozed for at most a year")
+	}
+	return nil
+}
+
+// CompleteReminder defaults to now and the car's latest odometer reading.
+type CompleteReminder struct {
+	Date     *time.Time `json:"date"`
+	Odometer int        `json:"odometer"`
+}
+
+func (c *CompleteReminder) Valid() error {
+	if c.Date != nil && c.Date.After(time.Now().Add(24*time.Hour)) {
+		return invalid("Error: Date can not be in the future")
+	}
+	if c.Odometer < 0 {
+		return invalid("Error: Odometer can not be negative")
+	}
+	return nil
+}
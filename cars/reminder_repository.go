@@ -0,0 +1,186 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type ReminderRepository struct {
+	db                 *mongo.Database
+	reminderCollection string
+}
+
+func NewInstanceOfReminderRepository(db *mongo.Database) ReminderRepository {
+	return ReminderRepository{db: db, reminderCollection: "carReminders"}
+}
+
+// CreateIndexes sets up the indexes to list a car's reminders and to find the ones the scheduler checks. It is
+// safe to call on every start.
+func (r *ReminderRepository) CreateIndexes() error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "carId", Value: 1}, {Key: "created", Value: 1}},
+			Options: options.Index().SetName("remindersCar"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "snoozedUntil", Value: 1}},
+			Options: options.Index().SetName("remindersStatusSnooze"),
+		},
+	}
+	_, err := r.db.Collection(r.reminderCollection).Indexes().CreateMany(context.TODO(), indexes)
+	return err
+}
+
+// List returns the car's reminders, oldest first.
+func (r *ReminderRepository) List(carID string) ([]Reminder, error) {
+	options := options.Find().SetSort(bson.D{{Key: "created", Value: 1}})
+	cursor, err := r.db.Collection(r.reminderCollection).Find(context.TODO(), bson.M{"carId": carID}, options)
+	if err != nil {
+		return []Reminder{}, err
+	}
+	reminders := []Reminder{}
+	if err := cursor.All(context.TODO(), &reminders); err != nil {
+		return []Reminder{}, err
+	}
+	return reminders, nil
+}
+
+// EachActive calls fn with every active reminder that is not snoozed at the time, for the scheduler.
+func (r *ReminderRepository) EachActive(now time.Time, fn func(Reminder) error) error {
+	filter := bson.M{
+		"status": ReminderActive,
+		"$or": []bson.M{
+			{"snoozedUntil": nil},
+			{"snoozedUntil": bson.M{"$lte": now}},
+		},
+	}
+	cursor, err := r.db.Collection(r.reminderCollection).Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var reminder Reminder
+		if err := cursor.Decode(&reminder); err != nil {
+			return err
+		}
+		if err := fn(reminder); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *ReminderRepository) Get(carID string, reminderID string) (Reminder, error) {
+	docID, err := primitive.ObjectIDFromHex(reminderID)
+	if err != nil {
+		return Reminder{}, ErrReminderNotFound
+	}
+
+	var reminder Reminder
+	err = r.db.Collection(r.reminderCollection).FindOne(context.TODO(), bson.M{"_id": docID, "carId": carID}).Decode(&reminder)
+	if err == mongo.ErrNoDocuments {
+		return Reminder{}, ErrReminderNotFound
+	}
+	if err != nil {
+		return Reminder{}, err
+	}
+	return reminder, nil
+}
+
+func (r *ReminderRepository) Save(reminder Reminder) (primitive.ObjectID, error) {
+	insertResult, err := r.db.Collection(r.reminderCollection).InsertOne(context.TODO(), reminder)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *ReminderRepository) Snooze(carID string, reminderID primitive.ObjectID, until time.Time) error {
+	filter := bson.M{"_id": reminderID, "carId": carID, "status": ReminderActive}
+	result, err := r.db.Collection(r.reminderCollection).UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"snoozedUntil": until}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// Complete stores the completion with the reminder's next due point and status, and ends any snooze.
+func (r *ReminderRepository) Complete(carID string, reminder Reminder) error {
+	filter := bson.M{"_id": reminder.ID, "carId": carID, "status": ReminderActive}
+	update := bson.M{
+		"$set": bson.M{
+			"status":        reminder.Status,
+			"dueDate":       reminder.DueDate,
+			"dueOdometer":   reminder.DueOdometer,
+			"lastCompleted": reminder.LastCompleted,
+		},
+		"$unset": bson.M{"snoozedUntil": ""},
+	}
+	result, err := r.db.Collection(r.reminderCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// MarkNotified claims the due window for an email. It returns false when the window was already claimed, so each
+// window is emailed once even with several schedulers running.
+func (r *ReminderRepository) MarkNotified(reminderID primitive.ObjectID, window string, now time.Time) (bool, error) {
+	filter := bson.M{"_id": reminderID, "notifiedWindow": bson.M{"$ne": window}}
+	update := bson.M{"$set": bson.M{"notifiedWindow": window, "notifiedAt": now}}
+	result, err := r.db.Collection(r.reminderCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// UnmarkNotified gives the window back when the email could not be sent, so the next run tries again.
+func (r *ReminderRepository) UnmarkNotified(reminderID primitive.ObjectID, window string) error {
+	filter := bson.M{"_id": reminderID, "notifiedWindow": window}
+	_, err := r.db.Collection(r.reminderCollection).UpdateOne(context.TODO(), filter, bson.M{"$unset": bson.M{"notifiedWindow": "", "notifiedAt": ""}})
+	return err
+}
+
+func (r *ReminderRepository) Delete(carID string, reminderID string) error {
+	docID, err := primitive.ObjectIDFromHex(reminderID)
+	if err != nil {
+		return ErrReminderNotFound
+	}
+
+	result, err := r.db.Collection(r.reminderCollection).DeleteOne(context.TODO(), bson.M{"_id": docID, "carId": carID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// DeleteForDocuments removes the renewal reminders of the documents.
+func (r *ReminderRepository) DeleteForDocuments(carID string, documentIDs []string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	_, err := r.db.Collection(r.reminderCollection).DeleteMany(context.TODO(), bson.M{"carId": carID, "documentId": bson.M{"$in": documentIDs}})
+	return err
+}
+
+// DeleteForCar removes every reminder of a car, used when the car is purged.
+func (r *ReminderRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.reminderCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
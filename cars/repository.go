@@ -2,6 +2,7 @@ package cars
 
 import (
 	"context"
+	"time"
 
 	// "database/sql"
 	// "github.com/jmoiron/sqlx"
@@ -20,90 +21,470 @@ func NewInstanceOfCarsRepository(db *mongo.Database) Repository {
 	return Repository{db: db, collectionName: "cars"}
 }
 
-func (c *Repository) List(email string, query ListCarQuery) ([]Car, error) {
-	filters := query.Filter(email)
-	var cars []Car
+// List returns a page of cars. By default it uses cursor (keyset) paging, which is stable under inserts and
+// does not slow down on later pages. Setting query.Page uses the older skip/limit paging.
+func (c *Repository) List(owner Owner, query ListCarQuery) (CarPage, error) {
+	filters := query.Filter(owner)
+	sortFields := query.SortFields()
+	page := CarPage{Cars: []Car{}}
 
-	options := options.Find()
+	if query.IncludeTotal {
+		total, err := c.db.Collection(c.collectionName).CountDocuments(context.Background(), filters)
+		if err != nil {
+			return CarPage{}, err
+		}
+		page.Total = &total
+	}
 
-	// Add paging
-	options.SetLimit(int64(query.Limit))
-	options.SetSkip(int64((query.Page * query.Limit) - query.Limit))
+	pipeline := matchPipeline(filters, query)
 
-	// Add timestamp
-	options.SetSort(bson.M{"created": -1})
+	cursor := pageCursor{}
+	if query.Page > 0 {
+		// Add paging
+		pipeline = append(pipeline,
+			bson.D{{Key: "$sort", Value: sortDocument(sortFields, false)}},
+			bson.D{{Key: "$skip", Value: int64((query.Page * query.Limit) - query.Limit)}},
+			bson.D{{Key: "$limit", Value: int64(query.Limit)}},
+		)
+	} else {
+		if query.Cursor != "" {
+			var err error
+			cursor, err = decodeCursor(query.Cursor, sortFields)
+			if err != nil {
+				return CarPage{}, err
+			}
+			pipeline = append(pipeline, bson.D{{Key: "$match", Value: keysetFilter(cursor, sortFields)}})
+		}
+		// Grab one extra to know if there is another page
+		pipeline = append(pipeline,
+			bson.D{{Key: "$sort", Value: sortDocument(sortFields, cursor.Previous)}},
+			bson.D{{Key: "$limit", Value: int64(query.Limit + 1)}},
+		)
+	}
 
-	cursor, err := c.db.Collection(c.collectionName).Find(context.Background(), filters, options)
+	results, err := c.db.Collection(c.collectionName).Aggregate(context.Background(), pipeline)
 	if err != nil {
-		return []Car{}, err
+		return CarPage{}, err
 	}
 
-	for cursor.Next(context.Background()) {
+	for results.Next(context.Background()) {
 		car := Car{}
-		err := cursor.Decode(&car)
+		err := results.Decode(&car)
 		if err != nil {
 			//handle err
 		} else {
-			cars = append(cars, car)
+			page.Cars = append(page.Cars, car)
 		}
 	}
-	return cars, nil
+
+	if query.Page > 0 {
+		return page, nil
+	}
+
+	hasMore := len(page.Cars) > query.Limit
+	if hasMore {
+		page.Cars = page.Cars[:query.Limit]
+	}
+
+	hasNext, hasPrev := hasMore, query.Cursor != ""
+	if cursor.Previous {
+		// Paging backwards reads in reverse order, flip it back
+		for i, j := 0, len(page.Cars)-1; i < j; i, j = i+1, j-1 {
+			page.Cars[i], page.Cars[j] = page.Cars[j], page.Cars[i]
+		}
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if len(page.Cars) > 0 {
+		if hasNext {
+			page.NextCursor, err = encodeCursor(page.Cars[len(page.Cars)-1], sortFields, false)
+			if err != nil {
+				return CarPage{}, err
+			}
+		}
+		if hasPrev {
+			page.PrevCursor, err = encodeCursor(page.Cars[0], sortFields, true)
+			if err != nil {
+				return CarPage{}, err
+			}
+		}
+	}
+	return page, nil
 }
 
-func (c *Repository) Get(email string, carID string) (Car, error) {
+// matchPipeline starts the listing aggregation. It is an aggregation rather than a find so the text search
+// score can be sorted and paged on like any other field. The $text match has to be the first stage.
+func matchPipeline(filters bson.M, query ListCarQuery) mongo.Pipeline {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filters}}}
+	if query.Search != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}})
+	}
+	return pipeline
+}
+
+// Each calls fn with every car matching the query in its sort order, ignoring paging. Cars are read from the
+// database as they are needed so any number can be gone through. It stops at the first error from fn.
+func (c *Repository) Each(owner Owner, query ListCarQuery, fn func(Car) error) error {
+	pipeline := matchPipeline(query.Filter(owner), query)
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sortDocument(query.SortFields(), false)}})
+
+	results, err := c.db.Collection(c.collectionName).Aggregate(context.Background(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer results.Close(context.Background())
+
+	for results.Next(context.Background()) {
+		car := Car{}
+		if err := results.Decode(&car); err != nil {
+			return err
+		}
+		if err := fn(car); err != nil {
+			return err
+		}
+	}
+	return results.Err()
+}
+
+// Stats counts the owner's cars in a single aggregation, each count being one facet of it. now is used for the
+// cars' ages. The spend is totalled in the same aggregation by looking up the cars' service records and fuel logs.
+func (c *Repository) Stats(owner Owner, now time.Time) (CarStats, error) {
+	filter := owner.Filter()
+	filter["deletedAt"] = nil
+	// Cars from before statuses have none and are active
+	status := bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$status", ""}}, ""}}, StatusActive, "$status"}}
+	byCount := bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"byMake": bson.A{
+				bson.M{"$group": bson.M{"_id": "$make", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": byCount},
+				bson.M{"$project": bson.M{"_id": 0, "value": "$_id", "count": 1}},
+			},
+			"byModel": bson.A{
+				bson.M{"$group": bson.M{"_id": bson.M{"make": "$make", "model": "$model"}, "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": byCount},
+				bson.M{"$project": bson.M{"_id": 0, "make": "$_id.make", "model": "$_id.model", "count": 1}},
+			},
+			"byYear": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$subtract": bson.A{"$year", bson.M{"$mod": bson.A{"$year", statsYearBucket}}}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+				bson.M{"$project": bson.M{"_id": 0, "from": "$_id", "to": bson.M{"$add": bson.A{"$_id", statsYearBucket - 1}}, "count": 1}},
+			},
+			"byStatus": bson.A{
+				bson.M{"$group": bson.M{"_id": status, "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": byCount},
+				bson.M{"$project": bson.M{"_id": 0, "value": "$_id", "count": 1}},
+			},
+			"age": bson.A{
+				bson.M{"$group": bson.M{"_id": nil, "average": bson.M{"$avg": bson.M{"$subtract": bson.A{now.Year(), "$year"}}}}},
+			},
+			"maintenance": spendFacet("carServiceRecords", "cost"),
+			"fuel":        spendFacet("carFuelLogs", "price"),
+		}}},
+		// The single value facets come back as lists of one, or empty when there are no cars
+		{{Key: "$project", Value: bson.M{
+			"byMake":      1,
+			"byModel":     1,
+			"byYear":      1,
+			"byStatus":    1,
+			"maintenance": 1,
+			"fuel":        1,
+			"total":       bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$total.count", 0}}, 0}},
+			"averageAge":  bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$age.average", 0}}, 0}},
+		}}},
+	}
+	results, err := c.db.Collection(c.collectionName).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return CarStats{}, err
+	}
+	defer results.Close(context.TODO())
+
+	var stats CarStats
+	if results.Next(context.TODO()) {
+		if err := results.Decode(&stats); err != nil {
+			return CarStats{}, err
+		}
+	}
+	return stats, results.Err()
+}
+
+// spendFacet adds up the amount field of the documents in the collection that belong to the cars, by currency.
+// The records keep the car ID as a string, so the cars' IDs are turned into strings to join on.
+func spendFacet(collection string, amount string) bson.A {
+	return bson.A{
+		bson.M{"$project": bson.M{"carId": bson.M{"$toString": "$_id"}}},
+		bson.M{"$lookup": bson.M{"from": collection, "localField": "carId", "foreignField": "carId", "as": "spend"}},
+		bson.M{"$unwind": "$spend"},
+		bson.M{"$group": bson.M{
+			"_id":   "$spend.currency",
+			"count": bson.M{"$sum": 1},
+			"total": bson.M{"$sum": "$spend." + amount},
+		}},
+		bson.M{"$project": bson.M{"_id": 0, "currency": "$_id", "count": 1, "total": 1}},
+		bson.M{"$sort": bson.M{"currency": 1}},
+	}
+}
+
+// Get looks up the car without checking who owns it. The service layer must authorize the result. Cars in the
+// trash are not found.
+func (c *Repository) Get(carID string) (Car, error) {
+	return c.get(carID, false)
+}
+
+// GetDeleted looks up a car in the trash, the same way as Get.
+func (c *Repository) GetDeleted(carID string) (Car, error) {
+	return c.get(carID, true)
+}
+
+func (c *Repository) get(carID string, deleted bool) (Car, error) {
 	docID, err := primitive.ObjectIDFromHex(carID)
 	if err != nil {
-		return Car{}, err
+		return Car{}, ErrCarNotFound
 	}
 
-	filter := bson.M{"_id": docID, "email": email}
+	filter := bson.M{"_id": docID, "deletedAt": nil}
+	if deleted {
+		filter["deletedAt"] = bson.M{"$ne": nil}
+	}
 
 	var result Car
 	err = c.db.Collection(c.collectionName).FindOne(context.TODO(), filter).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return Car{}, ErrCarNotFound
+	}
 	if err != nil {
 		return Car{}, err
 	}
 	return result, nil
 }
 
-func (c *Repository) Save(car Car) error {
-	_, err := c.db.Collection(c.collectionName).InsertOne(context.TODO(), car)
+// CreateIndexes sets up the indexes the car queries rely on. It is safe to call on every start.
+func (c *Repository) CreateIndexes() error {
+	textIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "make", Value: "text"},
+			{Key: "model", Value: "text"},
+			{Key: "notes", Value: "text"},
+			{Key: "tags", Value: "text"},
+		},
+		// Matches on the make or model count for more than matches buried in the notes
+		Options: options.Index().SetName("carsTextSearch").SetWeights(bson.M{"make": 10, "model": 10, "tags": 5, "notes": 1}),
+	}
+	// An owner can only have one car with a VIN, but cars without a VIN are left out
+	vinIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "ownerKey", Value: 1},
+			{Key: "vin", Value: 1},
+		},
+		Options: options.Index().SetName("carsOwnerVIN").SetUnique(true).SetPartialFilterExpression(bson.M{"vin": bson.M{"$exists": true}}),
+	}
+	// Only cars in the trash are indexed, for the purge
+	deletedIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetName("carsDeletedAt").SetPartialFilterExpression(bson.M{"deletedAt": bson.M{"$exists": true}}),
+	}
+	_, err := c.db.Collection(c.collectionName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{textIndex, vinIndex, deletedIndex})
+	return err
+}
+
+// Save inserts the car and returns it with its new ID.
+func (c *Repository) Save(car Car) (Car, error) {
+	insertResult, err := c.db.Collection(c.collectionName).InsertOne(context.TODO(), car)
+	if mongo.IsDuplicateKeyError(err) {
+		return Car{}, ErrDuplicateVIN
+	}
 	if err != nil {
-		return err
+		return Car{}, err
 	}
-	// insertResult.InsertedID.(string)
-	return nil
+	car.ID = insertResult.InsertedID.(primitive.ObjectID)
+	return car, nil
 }
 
-func (c *Repository) Update(email string, carID string, body UpdateCar) error {
+// Update changes the car's fields and, when statusChange is set, moves its status and records the change. The
+// update only happens if the car is still at the version it was read at, otherwise ErrCarChanged is returned and
+// nothing is changed.
+func (c *Repository) Update(owner Owner, carID string, version int, body UpdateCar, statusChange *StatusChange) error {
 	docID, err := primitive.ObjectIDFromHex(carID)
 	if err != nil {
-		return err
+		return ErrCarNotFound
 	}
 
 	update := body.Update()
+	filter := owner.Filter()
+	filter["_id"] = docID
+	filter["deletedAt"] = nil
+	filter["version"] = versionFilter(version)
+
+	if body.VIN != "" {
+		// Cars from before VINs have no owner key yet
+		update["$set"].(bson.M)["ownerKey"] = owner.Key()
+	}
+	if statusChange != nil {
+		if update == nil {
+			update = bson.M{}
+		}
+		if update["$set"] == nil {
+			update["$set"] = bson.M{}
+		}
+		update["$set"].(bson.M)["status"] = statusChange.To
+		update["$push"] = bson.M{"statusHistory": statusChange}
+	}
 	if update == nil {
 		return nil
 	}
-	filter := bson.M{"_id": docID, "email": email}
+	update["$inc"] = bson.M{"version": 1}
 
-	_, err = c.db.Collection(c.collectionName).UpdateOne(context.TODO(), filter, update)
+	result, err := c.db.Collection(c.collectionName).UpdateOne(context.TODO(), filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateVIN
+	}
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return ErrCarChanged
+	}
 	return nil
 }
 
-func (c *Repository) Delete(email string, carID string) error {
+// SetPrimaryPhoto stores the car's primary photo, or removes it when photo is nil. Photos are not part of the
+// car's own fields so the version is left alone, otherwise uploading a photo would fail the next If-Match.
+func (c *Repository) SetPrimaryPhoto(carID string, photo *CarPhoto) error {
 	docID, err := primitive.ObjectIDFromHex(carID)
+	if err != nil {
+		return ErrCarNotFound
+	}
+
+	update := bson.M{"$set": bson.M{"primaryPhoto": photo}}
+	if photo == nil {
+		update = bson.M{"$unset": bson.M{"primaryPhoto": ""}}
+	}
+	result, err := c.db.Collection(c.collectionName).UpdateOne(context.TODO(), bson.M{"_id": docID, "deletedAt": nil}, update)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return ErrCarNotFound
+	}
+	return nil
+}
+
+// VINExists reports whether the owner already has a car with the VIN.
+func (c *Repository) VINExists(owner Owner, vin string) (bool, error) {
+	count, err := c.db.Collection(c.collectionName).CountDocuments(context.TODO(), bson.M{"ownerKey": owner.Key(), "vin": vin})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
 
-	filter := bson.M{"_id": docID, "email": email}
-	_, err = c.db.Collection(c.collectionName).DeleteOne(context.TODO(), filter)
+// Delete moves the car to the trash. Its owner key is swapped for one of its own so the VIN is free for another
+// car while it is in the trash. It returns ErrCarChanged when the car is no longer at the version.
+func (c *Repository) Delete(owner Owner, carID string, version int, email string) error {
+	docID, err := primitive.ObjectIDFromHex(carID)
+	if err != nil {
+		return ErrCarNotFound
+	}
+
+	filter := owner.Filter()
+	filter["_id"] = docID
+	filter["deletedAt"] = nil
+	filter["version"] = versionFilter(version)
+	update := bson.M{
+		"$set": bson.M{"deletedAt": time.Now(), "deletedBy": email, "ownerKey": "deleted:" + carID},
+		"$inc": bson.M{"version": 1},
+	}
+	result, err := c.db.Collection(c.collectionName).UpdateOne(context.TODO(), filter, update)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return ErrCarChanged
+	}
 	return nil
 }
+
+// Restore takes the car out of the trash. It fails with ErrDuplicateVIN when the owner has added another car with
+// the same VIN since.
+func (c *Repository) Restore(car Car) error {
+	filter := car.Owner().Filter()
+	filter["_id"] = car.ID
+	filter["deletedAt"] = bson.M{"$ne": nil}
+	update := bson.M{
+		"$set":   bson.M{"ownerKey": car.Owner().Key()},
+		"$unset": bson.M{"deletedAt": "", "deletedBy": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	result, err := c.db.Collection(c.collectionName).UpdateOne(context.TODO(), filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateVIN
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCarNotFound
+	}
+	return nil
+}
+
+// ListDeletedBefore returns the cars that went in the trash before the cutoff, with only their ID and owner.
+func (c *Repository) ListDeletedBefore(cutoff time.Time) ([]Car, error) {
+	options := options.Find().SetProjection(bson.M{"_id": 1, "email": 1, "organizationId": 1})
+	cursor, err := c.db.Collection(c.collectionName).Find(context.TODO(), bson.M{"deletedAt": bson.M{"$lte": cutoff}}, options)
+	if err != nil {
+		return []Car{}, err
+	}
+	cars := []Car{}
+	if err := cursor.All(context.TODO(), &cars); err != nil {
+		return []Car{}, err
+	}
+	return cars, nil
+}
+
+// Purge deletes the car for good, as long as it is still in the trash from before the cutoff. It returns false
+// when the car was restored in the meantime.
+func (c *Repository) Purge(carID primitive.ObjectID, cutoff time.Time) (bool, error) {
+	result, err := c.db.Collection(c.collectionName).DeleteOne(context.TODO(), bson.M{"_id": carID, "deletedAt": bson.M{"$lte": cutoff}})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// Transfer moves the car to the new owner and records the previous owner in one update. The filter includes
+// the current owner so it does nothing (returns false) if the car changed hands in the meantime.
+func (c *Repository) Transfer(car Car, toEmail string, transferID string) (bool, error) {
+	filter := car.Owner().Filter()
+	filter["_id"] = car.ID
+	filter["deletedAt"] = nil
+
+	previousOwner := OwnershipRecord{
+		Email:          car.Email,
+		OrganizationID: car.OrganizationID,
+		TransferID:     transferID,
+		TransferredAt:  time.Now(),
+	}
+	update := bson.M{
+		"$set":   bson.M{"email": toEmail, "ownerKey": Owner{Email: toEmail}.Key()},
+		"$unset": bson.M{"organizationId": ""},
+		"$push":  bson.M{"ownershipHistory": previousOwner},
+		"$inc":   bson.M{"version": 1},
+	}
+	result, err := c.db.Collection(c.collectionName).UpdateOne(context.TODO(), filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		// The new owner already has a car with this VIN
+		return false, ErrDuplicateVIN
+	}
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
@@ -0,0 +1,181 @@
+package cars
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrRevisionNotFound = errors.New("Error: Revision not found")
+var ErrNothingToRevert = errors.New("Error: The car already matches the revision")
+var errRevisionNumberTaken = errors.New("Error: Could not number the revision, the car changed too many times at once")
+
+// Revision is one change to a car: who made it, when, and each field's value before and after. The first revision
+// of a car is its creation. Cars created before revisions were kept start with their first change.
+type Revision struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CarID      string             `json:"carId" bson:"carId"`
+	Number     int                `json:"number" bson:"number"` // Counts up from 1 for each car
+	Changes    []FieldChange      `json:"changes" bson:"changes"`
+	Snapshot   CarSnapshot        `json:"snapshot" bson:"snapshot"`                         // The tracked fields right after the change
+	RevertedTo int                `json:"revertedTo,omitempty" bson:"revertedTo,omitempty"` // Set when the change reverted to an earlier revision
+	Email      string             `json:"email" bson:"email"`
+	Created    time.Time          `json:"created" bson:"created"`
+}
+
+// FieldChange is a field's value before and after a change. Before is left out for a car's creation.
+type FieldChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After  interface{} `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// CarSnapshot is the car's fields that revisions track.
+type CarSnapshot struct {
+	Make   string   `json:"make" bson:"make"`
+	Model  string   `json:"model" bson:"model"`
+	Year   int      `json:"year" bson:"year"`
+	Status string   `json:"status" bson:"status"`
+	VIN    string   `json:"vin,omitempty" bson:"vin,omitempty"`
+	Notes  string   `json:"notes,omitempty" bson:"notes,omitempty"`
+	Tags   []string `json:"tags,omitempty" bson:"tags,omitempty"`
+}
+
+// revisionFields are the tracked fields in the order changes are listed.
+var revisionFields = []struct {
+	Field string
+	Value func(CarSnapshot) interface{}
+}{
+	{"make", func(s CarSnapshot) interface{} { return s.Make }},
+	{"model", func(s CarSnapshot) interface{} { return s.Model }},
+	{"year", func(s CarSnapshot) interface{} { return s.Year }},
+	{"status", func(s CarSnapshot) interface{} { return s.Status }},
+	{"vin", func(s CarSnapshot) interface{} { return s.VIN }},
+	{"notes", func(s CarSnapshot) interface{} { return s.Notes }},
+	{"tags", func(s CarSnapshot) interface{} { return s.Tags }},
+}
+
+func snapshotOf(car Car) CarSnapshot {
+	return CarSnapshot{
+		Make:   car.Make,
+		Model:  car.Model,
+		Year:   car.Year,
+		Status: car.CurrentStatus(),
+		VIN:    car.VIN,
+		Notes:  car.Notes,
+		Tags:   car.Tags,
+	}
+}
+
+// diffSnapshots lists the fields that differ. A nil before lists every field that is set in after, for a creation.
+func diffSnapshots(before *CarSnapshot, after CarSnapshot) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range revisionFields {
+		afterValue := emptyToNil(field.Value(after))
+		if before == nil {
+			if afterValue != nil {
+				changes = append(changes, FieldChange{Field: field.Field, After: afterValue})
+			}
+			continue
+		}
+		beforeValue := emptyToNil(field.Value(*before))
+		if !reflect.DeepEqual(beforeValue, afterValue) {
+			changes = append(changes, FieldChange{Field: field.Field, Before: beforeValue, After: afterValue})
+		}
+	}
+	return changes
+}
+
+// emptyToNil treats unset fields and empty lists the same, so clearing the tags on a car without any is no change.
+func emptyToNil(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+	case int:
+		if v == 0 {
+			return nil
+		}
+	case []string:
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	return value
+}
+
+// apply is the snapshot after the update.
+func (u *UpdateCar) apply(snapshot CarSnapshot) CarSnapshot {
+	if u.Make != "" {
+		snapshot.Make = u.Make
+	}
+	if u.Model != "" {
+		snapshot.Model = u.Model
+	}
+	if u.Year != 0 {
+		snapshot.Year = u.Year
+	}
+	if u.Status != "" {
+		snapshot.Status = u.Status
+	}
+	if u.VIN != "" {
+		snapshot.VIN = u.VIN
+	}
+	if u.Notes != "" {
+		snapshot.Notes = u.Notes
+	}
+	if u.Tags != nil {
+		snapshot.Tags = u.Tags
+	}
+	for _, field := range u.Clear {
+		switch field {
+		case "vin":
+			snapshot.VIN = ""
+		case "notes":
+			snapshot.Notes = ""
+		}
+	}
+	return snapshot
+}
+
+// updateTo is the update that takes the car from current to the target snapshot. Fields that are not set in the
+// target are cleared, apart from the make, model, year and status which every car has.
+func updateTo(current CarSnapshot, target CarSnapshot) UpdateCar {
+	body := UpdateCar{}
+	if target.Make != "" && target.Make != current.Make {
+		body.Make = target.Make
+	}
+	if target.Model != "" && target.Model != current.Model {
+		body.Model = target.Model
+	}
+	if target.Year != 0 && target.Year != current.Year {
+		body.Year = target.Year
+	}
+	if target.Status != "" && target.Status != current.Status {
+		body.Status = target.Status
+	}
+	if target.VIN != current.VIN {
+		if target.VIN == "" {
+			body.Clear = append(body.Clear, "vin")
+		} else {
+			body.VIN = target.VIN
+		}
+	}
+	if target.Notes != current.Notes {
+		if target.Notes == "" {
+			body.Clear = append(body.Clear, "notes")
+		} else {
+			body.Notes = target.Notes
+		}
+	}
+	if !reflect.DeepEqual(emptyToNil(target.Tags), emptyToNil(current.Tags)) {
+		body.Tags = target.Tags
+		if body.Tags == nil {
+			body.Tags = []string{}
+		}
+	}
+	return body
+}
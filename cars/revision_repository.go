@@ -0,0 +1,93 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+)
+
+type RevisionRepository struct {
+	db                 *mongo.Database
+	revisionCollection string
+}
+
+func NewInstanceOfRevisionRepository(db *mongo.Database) RevisionRepository {
+	return RevisionRepository{db: db, revisionCollection: "carRevisions"}
+}
+
+// CreateIndexes sets up the index that keeps revision numbers unique per car. It is safe to call on every start.
+func (r *RevisionRepository) CreateIndexes() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "carId", Value: 1}, {Key: "number", Value: -1}},
+		Options: options.Index().SetName("revisionsCarNumber").SetUnique(true),
+	}
+	_, err := r.db.Collection(r.revisionCollection).Indexes().CreateOne(context.TODO(), index)
+	return err
+}
+
+// List returns the car's revisions, newest first.
+func (r *RevisionRepository) List(carID string) ([]Revision, error) {
+	options := options.Find().SetSort(bson.D{{Key: "number", Value: -1}})
+	cursor, err := r.db.Collection(r.revisionCollection).Find(context.TODO(), bson.M{"carId": carID}, options)
+	if err != nil {
+		return []Revision{}, err
+	}
+	revisions := []Revision{}
+	if err := cursor.All(context.TODO(), &revisions); err != nil {
+		return []Revision{}, err
+	}
+	return revisions, nil
+}
+
+func (r *RevisionRepository) Get(carID string, number string) (Revision, error) {
+	numberInt, err := strconv.Atoi(number)
+	if err != nil {
+		return Revision{}, ErrRevisionNotFound
+	}
+
+	var revision Revision
+	err = r.db.Collection(r.revisionCollection).FindOne(context.TODO(), bson.M{"carId": carID, "number": numberInt}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return Revision{}, ErrRevisionNotFound
+	}
+	if err != nil {
+		return Revision{}, err
+	}
+	return revision, nil
+}
+
+// revisionAttempts is how many times Save tries for a number when other changes to the car take it first.
+const revisionAttempts = 5
+
+// Save gives the revision the car's next number and stores it.
+func (r *RevisionRepository) Save(revision Revision) (Revision, error) {
+	for attempt := 0; attempt < revisionAttempts; attempt++ {
+		var latest Revision
+		options := options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}}).SetProjection(bson.M{"number": 1})
+		err := r.db.Collection(r.revisionCollection).FindOne(context.TODO(), bson.M{"carId": revision.CarID}, options).Decode(&latest)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return Revision{}, err
+		}
+		revision.Number = latest.Number + 1
+
+		insertResult, err := r.db.Collection(r.revisionCollection).InsertOne(context.TODO(), revision)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return Revision{}, err
+		}
+		revision.ID = insertResult.InsertedID.(primitive.ObjectID)
+		return revision, nil
+	}
+	return Revision{}, errRevisionNumberTaken
+}
+
+// DeleteForCar removes every revision of a car, used when the car is purged.
+func (r *RevisionRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.revisionCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
@@ -0,0 +1,191 @@
+package cars
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Service record types
+const (
+	ServiceOilChange  = "oil-change"
+	ServiceTires      = "tires"
+	ServiceBrakes     = "brakes"
+	ServiceBattery    = "battery"
+	ServiceInspection = "inspection"
+	ServiceScheduled  = "scheduled"
+	ServiceRepair     = "repair"
+	ServiceOther      = "other"
+)
+
+var serviceTypes = map[string]bool{
+	ServiceOilChange:  true,
+	ServiceTires:      true,
+	ServiceBrakes:     true,
+	ServiceBattery:    true,
+	ServiceInspection: true,
+	ServiceScheduled:  true,
+	ServiceRepair:     true,
+	ServiceOther:      true,
+}
+
+const (
+	maxServiceParts      = 100
+	maxServiceTextLength = 2000
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var ErrServiceRecordNotFound = errors.New("Error: Service record not found")
+
+// ServicePart is a part used in a service. Cost is per part in the minor unit of the record's currency.
+type ServicePart struct {
+	Name       string `json:"name" bson:"name"`
+	PartNumber string `json:"partNumber,omitempty" bson:"partNumber,omitempty"`
+	Quantity   int    `json:"quantity" bson:"quantity"`
+	Cost       int64  `json:"cost" bson:"cost"`
+}
+
+// ServiceRecord is one visit to the shop, or work done at home. Cost is the total in the minor unit of the
+// currency (cents for USD) so totals add up exactly.
+type ServiceRecord struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CarID    string             `json:"carId" bson:"carId"`
+	Date     time.Time          `json:"date" bson:"date"`
+	Odometer int                `json:"odometer" bson:"odometer"`
+	Type     string             `json:"type" bson:"type"`
+	Cost     int64              `json:"cost" bson:"cost"`
+	Currency string             `json:"currency,omitempty" bson:"currency,omitempty"`
+	Shop     string             `json:"shop,omitempty" bson:"shop,omitempty"`
+	Notes    string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	Parts    []ServicePart      `json:"parts" bson:"parts"`
+	Email    string             `json:"email" bson:"email"` // Who added the record
+	Created  time.Time          `json:"created" bson:"created"`
+	Updated  *time.Time         `json:"updated,omitempty" bson:"updated,omitempty"`
+}
+
+// ServiceTotal is what was spent on a car in a year. Records in different currencies are totalled separately.
+type ServiceTotal struct {
+	Year     int    `json:"year" bson:"year"`
+	Currency string `json:"currency,omitempty" bson:"currency,omitempty"`
+	Count    int    `json:"count" bson:"count"`
+	Cost     int64  `json:"cost" bson:"cost"`
+}
+
+type CreateServiceRecord struct {
+	Date     time.Time     `json:"date"`
+	Odometer int           `json:"odometer"`
+	Type     string        `json:"type"`
+	Cost     int64         `json:"cost"`
+	Currency string        `json:"currency"`
+	Shop     string        `json:"shop"`
+	Notes    string        `json:"notes"`
+	Parts    []ServicePart `json:"parts"`
+}
+
+func (c *CreateServiceRecord) Valid() error {
+	if c.Date.IsZero() {
+		return invalid("Error: Date is missing")
+	}
+	if c.Type == "" {
+		return invalid("Error: Type is missing")
+	}
+	return validServiceFields(c.Date, c.Odometer, c.Type, c.Cost, c.Currency, c.Shop, c.Notes, c.Parts)
+}
+
+type UpdateServiceRecord struct {
+	Date     time.Time     `json:"date"`
+	Odometer int           `json:"odometer"`
+	Type     string        `json:"type"`
+	Cost     *int64        `json:"cost"` // A pointer so the cost can be set to zero, e.g. for warranty work
+	Currency string        `json:"currency"`
+	Shop     string        `json:"shop"`
+	Notes    string        `json:"notes"`
+	Parts    []ServicePart `json:"parts"` // Replaces all parts when set, an empty list clears them
+}
+
+func (u *UpdateServiceRecord) Valid() error {
+	cost := int64(0)
+	if u.Cost != nil {
+		cost = *u.Cost
+	}
+	return validServiceFields(u.Date, u.Odometer, u.Type, cost, u.Currency, u.Shop, u.Notes, u.Parts)
+}
+
+func (u *UpdateServiceRecord) Update() bson.M {
+	update := bson.M{}
+	if !u.Date.IsZero() {
+		update["date"] = u.Date
+	}
+	if u.Odometer != 0 {
+		update["odometer"] = u.Odometer
+	}
+	if u.Type != "" {
+		update["type"] = u.Type
+	}
+	if u.Cost != nil {
+		update["cost"] = *u.Cost
+	}
+	if u.Currency != "" {
+		update["currency"] = u.Currency
+	}
+	if u.Shop != "" {
+		update["shop"] = u.Shop
+	}
+	if u.Notes != "" {
+		update["notes"] = u.Notes
+	}
+	if u.Parts != nil {
+		update["parts"] = u.Parts
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	update["updated"] = time.Now()
+	return bson.M{"$set": update}
+}
+
+// yearStart is the first moment of the year in UTC, which is also what Mongo's $year uses.
+func yearStart(year int) time.Time {
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// validServiceFields checks the fields that are set. Zero values are left to the create and update checks.
+func validServiceFields(date time.Time, odometer int, serviceType string, cost int64, currency string, shop string, notes string, parts []ServicePart) error {
+	if date.After(time.Now().Add(24 * time.Hour)) {
+		return invalid("Error: Date can not be in the future")
+	}
+	if odometer < 0 {
+		return invalid("Error: Odometer can not be negative")
+	}
+	if serviceType != "" && !serviceTypes[serviceType] {
+		return invalid("Error: Unknown service type " + serviceType)
+	}
+	if cost < 0 {
+		return invalid("Error: Cost can not be negative")
+	}
+	if currency != "" && !currencyPattern.MatchString(currency) {
+		return invalid("Error: Currency must be a three letter code like USD")
+	}
+	if len(shop) > maxServiceTextLength || len(notes) > maxServiceTextLength {
+		return invalid("Error: Shop and notes can be at most 2000 characters")
+	}
+	if len(parts) > maxServiceParts {
+		return invalid("Error: A service can have at most 100 parts")
+	}
+	for _, part := range parts {
+		if part.Name == "" {
+			return invalid("Error: Part name is missing")
+		}
+		if part.Quantity < 1 {
+			return invalid("Error: Part quantity must be at least 1")
+		}
+		if part.Cost < 0 {
+			return invalid("Error: Part cost can not be negative")
+		}
+	}
+	return nil
+}
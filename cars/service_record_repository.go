@@ -0,0 +1,145 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ServiceRecordRepository struct {
+	db                      *mongo.Database
+	serviceRecordCollection string
+}
+
+func NewInstanceOfServiceRecordRepository(db *mongo.Database) ServiceRecordRepository {
+	return ServiceRecordRepository{db: db, serviceRecordCollection: "carServiceRecords"}
+}
+
+// CreateIndexes sets up the index used to list a car's records by date. It is safe to call on every start.
+func (r *ServiceRecordRepository) CreateIndexes() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "carId", Value: 1}, {Key: "date", Value: -1}},
+		Options: options.Index().SetName("serviceRecordsCarDate"),
+	}
+	_, err := r.db.Collection(r.serviceRecordCollection).Indexes().CreateOne(context.TODO(), index)
+	return err
+}
+
+// List returns the car's records, newest first. A year of zero returns every year.
+func (r *ServiceRecordRepository) List(carID string, year int) ([]ServiceRecord, error) {
+	filter := bson.M{"carId": carID}
+	if year != 0 {
+		filter["date"] = bson.M{
+			"$gte": yearStart(year),
+			"$lt":  yearStart(year + 1),
+		}
+	}
+	options := options.Find().SetSort(bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := r.db.Collection(r.serviceRecordCollection).Find(context.TODO(), filter, options)
+	if err != nil {
+		return []ServiceRecord{}, err
+	}
+	records := []ServiceRecord{}
+	if err := cursor.All(context.TODO(), &records); err != nil {
+		return []ServiceRecord{}, err
+	}
+	return records, nil
+}
+
+// Totals adds up the car's records by year and currency, newest year first.
+func (r *ServiceRecordRepository) Totals(carID string) ([]ServiceTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"carId": carID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"year": bson.M{"$year": "$date"}, "currency": "$currency"},
+			"count": bson.M{"$sum": 1},
+			"cost":  bson.M{"$sum": "$cost"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"year":     "$_id.year",
+			"currency": "$_id.currency",
+			"count":    1,
+			"cost":     1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "year", Value: -1}, {Key: "currency", Value: 1}}}},
+	}
+	cursor, err := r.db.Collection(r.serviceRecordCollection).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return []ServiceTotal{}, err
+	}
+	totals := []ServiceTotal{}
+	if err := cursor.All(context.TODO(), &totals); err != nil {
+		return []ServiceTotal{}, err
+	}
+	return totals, nil
+}
+
+func (r *ServiceRecordRepository) Get(carID string, recordID string) (ServiceRecord, error) {
+	docID, err := primitive.ObjectIDFromHex(recordID)
+	if err != nil {
+		return ServiceRecord{}, ErrServiceRecordNotFound
+	}
+
+	var record ServiceRecord
+	err = r.db.Collection(r.serviceRecordCollection).FindOne(context.TODO(), bson.M{"_id": docID, "carId": carID}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return ServiceRecord{}, ErrServiceRecordNotFound
+	}
+	if err != nil {
+		return ServiceRecord{}, err
+	}
+	return record, nil
+}
+
+func (r *ServiceRecordRepository) Save(record ServiceRecord) (primitive.ObjectID, error) {
+	insertResult, err := r.db.Collection(r.serviceRecordCollection).InsertOne(context.TODO(), record)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *ServiceRecordRepository) Update(carID string, recordID string, body UpdateServiceRecord) error {
+	docID, err := primitive.ObjectIDFromHex(recordID)
+	if err != nil {
+		return ErrServiceRecordNotFound
+	}
+
+	update := body.Update()
+	if update == nil {
+		return nil
+	}
+	result, err := r.db.Collection(r.serviceRecordCollection).UpdateOne(context.TODO(), bson.M{"_id": docID, "carId": carID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrServiceRecordNotFound
+	}
+	return nil
+}
+
+func (r *ServiceRecordRepository) Delete(carID string, recordID string) error {
+	docID, err := primitive.ObjectIDFromHex(recordID)
+	if err != nil {
+		return ErrServiceRecordNotFound
+	}
+
+	result, err := r.db.Collection(r.serviceRecordCollection).DeleteOne(context.TODO(), bson.M{"_id": docID, "carId": carID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrServiceRecordNotFound
+	}
+	return nil
+}
+
+// DeleteForCar removes every record of a car, used when the car is purged.
+func (r *ServiceRecordRepository) DeleteForCar(carID string) error {
+	_, err := r.db.Collection(r.serviceRecordCollection).DeleteMany(context.TODO(), bson.M{"carId": carID})
+	return err
+}
@@ -1,81 +1,1622 @@
 package cars
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go-boilerplate/audit"
+	"go-boilerplate/emails/cartransferemail"
+	"go-boilerplate/emails/maintenancereminderemail"
 	"go-boilerplate/logging"
+	"go-boilerplate/organizations"
+	"go-boilerplate/storage"
 	"go-boilerplate/user"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
-	// "fmt"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 	// "common"
-	// "github.com/google/uuid"
 )
 
 type Services struct {
-	logger         logging.Logger
-	userRepository user.Repository
-	carsRepository Repository
+	logger                  logging.Logger
+	userRepository          user.Repository
+	carsRepository          Repository
+	transferRepository      TransferRepository
+	importRepository        ImportRepository
+	serviceRecordRepository ServiceRecordRepository
+	fuelRepository          FuelRepository
+	mileageRepository       MileageRepository
+	reminderRepository      ReminderRepository
+	documentRepository      DocumentRepository
+	photoRepository         PhotoRepository
+	revisionRepository      RevisionRepository
+	fileStorage             storage.Storage
+	organizationsRepository organizations.Repository
+	auditServices           audit.Services
 }
 
-func NewInstanceOfCarsServices(logger logging.Logger, userRepository user.Repository, carsRepository Repository) Services {
-	return Services{logger, userRepository, carsRepository}
+func NewInstanceOfCarsServices(logger logging.Logger, userRepository user.Repository, carsRepository Repository, transferRepository TransferRepository, importRepository ImportRepository, serviceRecordRepository ServiceRecordRepository, fuelRepository FuelRepository, mileageRepository MileageRepository, reminderRepository ReminderRepository, documentRepository DocumentRepository, photoRepository PhotoRepository, revisionRepository RevisionRepository, fileStorage storage.Storage, organizationsRepository organizations.Repository, auditServices audit.Services) Services {
+	return Services{logger, userRepository, carsRepository, transferRepository, importRepository, serviceRecordRepository, fuelRepository, mileageRepository, reminderRepository, documentRepository, photoRepository, revisionRepository, fileStorage, organizationsRepository, auditServices}
 }
 
-func (c *Services) GetAll(ctx context.Context, session user.Session, query ListCarQuery) ([]Car, error) {
+func (c *Services) GetAll(ctx context.Context, session user.Session, query ListCarQuery) (CarPage, error) {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetAll")
 
-	cars, err := c.carsRepository.List(session.Email, query)
+	owner := Owner{Email: session.Email, OrganizationID: query.OrganizationID}
+	if err := c.authorize(ctx, session, owner, organizations.PermissionViewCars); err != nil {
+		return CarPage{}, err
+	}
+
+	page, err := c.carsRepository.List(owner, query)
 	if err != nil {
-		return []Car{}, err
+		return CarPage{}, err
 	}
-	return cars, nil
+	return page, nil
 }
 
 func (c *Services) GetByID(ctx context.Context, session user.Session, carID string) (Car, error) {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetByID")
 
-	car, err := c.carsRepository.Get(session.Email, carID)
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars)
 	if err != nil {
 		return Car{}, err
 	}
 	return car, nil
 }
 
-func (c *Services) Create(ctx context.Context, session user.Session, body CreateCar) error {
+// Create adds the car and returns it as stored.
+func (c *Services) Create(ctx context.Context, session user.Session, body CreateCar) (Car, error) {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Create")
-	// Create new car object
+
+	owner := Owner{Email: session.Email, OrganizationID: body.OrganizationID}
+	if err := c.authorize(ctx, session, owner, organizations.PermissionEditCars); err != nil {
+		return Car{}, err
+	}
+
+	car, err := c.carsRepository.Save(newCar(session, owner, body))
+	if err != nil {
+		return Car{}, err
+	}
+	carID := car.ID.Hex()
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarCreated, Email: session.Email, Resource: carID})
+	c.recordRevision(ctx, session, carID, nil, snapshotOf(car), 0)
+	return car, nil
+}
+
+// newCar builds a new active car from a checked body.
+func newCar(session user.Session, owner Owner, body CreateCar) Car {
 	car := Car{
 		Make:    body.Make,
 		Model:   body.Model,
 		Year:    body.Year,
-		Status:  "",
+		VIN:     body.VIN,
+		OwnerKey: owner.Key(),
+		Status:  StatusActive,
+		Notes:   body.Notes,
+		Tags:    body.Tags,
 		Created: time.Now(),
 		Email:   session.Email,
+		OrganizationID: owner.OrganizationID,
+		Version: 1,
 	}
-	err := c.carsRepository.Save(car)
+	if car.VIN != "" {
+		decoded := DecodeVIN(car.VIN)
+		car.VINDetails = &decoded
+	}
+	return car
+}
+
+// Update changes the car. ifMatch is the request's If-Match header, when it is set the car is only changed if it
+// still has one of the ETags listed.
+func (c *Services) Update(ctx context.Context, session user.Session, carID string, body UpdateCar, ifMatch string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Update")
+
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars)
 	if err != nil {
 		return err
 	}
-	return nil
+	if ifMatch != "" && !MatchesIfMatch(ifMatch, car.ETag()) {
+		return ErrPreconditionFailed
+	}
+	err = c.update(ctx, session, car, body, 0)
+	if err == ErrCarChanged && ifMatch != "" {
+		return ErrPreconditionFailed
+	}
+	return err
 }
 
-func (c *Services) Update(ctx context.Context, session user.Session, carID string, body UpdateCar) error {
-	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Update")
+// Patch changes the car with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), picked by the content type.
+// Unlike Update, a patch can clear fields by removing them or setting them to null. ifMatch works the same as
+// for Update.
+func (c *Services) Patch(ctx context.Context, session user.Session, carID string, contentType string, patch []byte, ifMatch string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Patch")
+
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars)
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" && !MatchesIfMatch(ifMatch, car.ETag()) {
+		return ErrPreconditionFailed
+	}
+
+	body, err := patchCar(snapshotOf(car), contentType, patch)
+	if err != nil {
+		return err
+	}
+	if len(body.Fields()) == 0 {
+		return nil
+	}
+	err = c.update(ctx, session, car, body, 0)
+	if err == ErrCarChanged && ifMatch != "" {
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+// update applies the change to the car and records it as a revision. revertedTo is the revision the change goes
+// back to, 0 for a normal update.
+func (c *Services) update(ctx context.Context, session user.Session, car Car, body UpdateCar, revertedTo int) error {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "update"))
+	carID := car.ID.Hex()
+	before := snapshotOf(car)
+	after := body.apply(before)
+
+	// The VIN has to agree with the make and year the car will have after the update
+	if after.VIN != "" {
+		if err := DecodeVIN(after.VIN).Check(after.Make, after.Year); err != nil {
+			return err
+		}
+	}
+
+	var statusChange *StatusChange
+	if body.Status != "" && body.Status != car.CurrentStatus() {
+		if !CanTransition(car.CurrentStatus(), body.Status) {
+			return invalid("Error: A car can not go from " + car.CurrentStatus() + " to " + body.Status)
+		}
+		statusChange = &StatusChange{
+			From:    car.CurrentStatus(),
+			To:      body.Status,
+			Reason:  body.StatusReason,
+			Email:   session.Email,
+			Changed: time.Now(),
+		}
+	}
+
 	// Update car
-	err := c.carsRepository.Update(session.Email, carID, body)
+	err := c.carsRepository.Update(car.Owner(), carID, car.Version, body, statusChange)
 	if err != nil {
 		return err
 	}
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarUpdated, Email: session.Email, Resource: carID, Metadata: map[string]string{"fields": strings.Join(body.Fields(), ",")}})
+	if statusChange != nil {
+		c.auditServices.Record(ctx, audit.Event{Type: audit.CarStatusChanged, Email: session.Email, Resource: carID, Metadata: map[string]string{"from": statusChange.From, "to": statusChange.To}})
+	}
+	c.recordRevision(ctx, session, carID, &before, after, revertedTo)
 	return nil
 }
 
-func (c *Services) Delete(ctx context.Context, session user.Session, carID string) error {
+// GetStatusHistory lists the car's status changes, oldest first.
+func (c *Services) GetStatusHistory(ctx context.Context, session user.Session, carID string) ([]StatusChange, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetStatusHistory")
+
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars)
+	if err != nil {
+		return nil, err
+	}
+	if car.StatusHistory == nil {
+		return []StatusChange{}, nil
+	}
+	return car.StatusHistory, nil
+}
+
+// GetRevisions lists the car's revisions, newest first.
+func (c *Services) GetRevisions(ctx context.Context, session user.Session, carID string) ([]Revision, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetRevisions")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return nil, err
+	}
+	revisions, err := c.revisionRepository.List(carID)
+	if err != nil {
+		c.logger.Error(ctx, "failed to list revisions", err)
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RevertToRevision changes the car's fields back to how they were right after the revision. The revert is itself
+// recorded as a new revision, so it can be undone the same way. A status change back still has to be one the car
+// is allowed to make.
+func (c *Services) RevertToRevision(ctx context.Context, session user.Session, carID string, number string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "RevertToRevision")
+
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars)
+	if err != nil {
+		return err
+	}
+	revision, err := c.revisionRepository.Get(carID, number)
+	if err != nil {
+		return err
+	}
+
+	body := updateTo(snapshotOf(car), revision.Snapshot)
+	if len(body.Fields()) == 0 {
+		return ErrNothingToRevert
+	}
+	if body.Status != "" {
+		body.StatusReason = "Reverted to revision " + strconv.Itoa(revision.Number)
+	}
+	return c.update(ctx, session, car, body, revision.Number)
+}
+
+// recordRevision stores the change as the car's next revision. before is nil for a new car. A revision that fails
+// to save is logged and the change it describes is kept.
+func (c *Services) recordRevision(ctx context.Context, session user.Session, carID string, before *CarSnapshot, after CarSnapshot, revertedTo int) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "recordRevision"))
+
+	changes := diffSnapshots(before, after)
+	if before != nil && len(changes) == 0 {
+		return
+	}
+	revision := Revision{
+		CarID:      carID,
+		Changes:    changes,
+		Snapshot:   after,
+		RevertedTo: revertedTo,
+		Email:      session.Email,
+		Created:    time.Now(),
+	}
+	if _, err := c.revisionRepository.Save(revision); err != nil {
+		c.logger.Error(ctx, "failed to save revision", err)
+	}
+}
+
+// Delete moves the car to the trash, where it can be restored until it is purged. Its records are kept until then.
+// ifMatch works the same as for Update.
+func (c *Services) Delete(ctx context.Context, session user.Session, carID string, ifMatch string) error {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Delete")
 
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionDeleteCars)
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" && !MatchesIfMatch(ifMatch, car.ETag()) {
+		return ErrPreconditionFailed
+	}
+
 	// Delete car
-	err := c.carsRepository.Delete(session.Email, carID)
+	err = c.carsRepository.Delete(car.Owner(), carID, car.Version, session.Email)
+	if err == ErrCarChanged && ifMatch != "" {
+		return ErrPreconditionFailed
+	}
 	if err != nil {
 		return err
 	}
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarDeleted, Email: session.Email, Resource: carID})
+
+	// A car in the trash can't be accepted, so there is no point keeping the transfer open
+	if err := c.transferRepository.CancelPending(carID); err != nil {
+		c.logger.Warning(ctx, "failed to cancel pending transfers", err)
+	}
 	return nil
 }
+
+// GetStats counts the owner's cars by make, model, model year and status, along with what was spent on them.
+func (c *Services) GetStats(ctx context.Context, session user.Session, organizationID string) (CarStats, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetStats")
+
+	owner := Owner{Email: session.Email, OrganizationID: organizationID}
+	if err := c.authorize(ctx, session, owner, organizations.PermissionViewCars); err != nil {
+		return CarStats{}, err
+	}
+
+	stats, err := c.carsRepository.Stats(owner, time.Now())
+	if err != nil {
+		c.logger.Error(ctx, "failed to count cars", err)
+		return CarStats{}, err
+	}
+	stats.AverageAge = math.Round(stats.AverageAge*10) / 10
+	return stats, nil
+}
+
+// GetTrash lists the cars in the trash with the same filters, sorting and paging as GetAll.
+func (c *Services) GetTrash(ctx context.Context, session user.Session, query ListCarQuery) (CarPage, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetTrash")
+
+	owner := Owner{Email: session.Email, OrganizationID: query.OrganizationID}
+	if err := c.authorize(ctx, session, owner, organizations.PermissionDeleteCars); err != nil {
+		return CarPage{}, err
+	}
+
+	query.Deleted = true
+	page, err := c.carsRepository.List(owner, query)
+	if err != nil {
+		return CarPage{}, err
+	}
+	return page, nil
+}
+
+// Restore takes the car out of the trash. It needs the same permission as deleting it.
+func (c *Services) Restore(ctx context.Context, session user.Session, carID string) (Car, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Restore")
+
+	car, err := c.carsRepository.GetDeleted(carID)
+	if err != nil {
+		return Car{}, err
+	}
+	err = c.authorize(ctx, session, car.Owner(), organizations.PermissionDeleteCars)
+	if err == ErrNotPermitted && !c.canView(ctx, session, car.Owner()) {
+		return Car{}, ErrCarNotFound
+	}
+	if err != nil {
+		return Car{}, err
+	}
+
+	if err := c.carsRepository.Restore(car); err != nil {
+		return Car{}, err
+	}
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarRestored, Email: session.Email, Resource: carID})
+	return c.carsRepository.Get(carID)
+}
+
+// StartTrashPurge purges the trash straight away and then every interval until the context is done.
+func (c *Services) StartTrashPurge(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			c.PurgeTrash(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeTrash deletes the cars that have been in the trash longer than the retention period, along with everything
+// recorded about them.
+func (c *Services) PurgeTrash(ctx context.Context) {
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "PurgeTrash")
+
+	cutoff := time.Now().Add(-GetTrashRetention())
+	cars, err := c.carsRepository.ListDeletedBefore(cutoff)
+	if err != nil {
+		c.logger.Error(ctx, "failed to list cars to purge", err)
+		return
+	}
+	for _, car := range cars {
+		purged, err := c.carsRepository.Purge(car.ID, cutoff)
+		if err != nil {
+			c.logger.Warning(ctx, "failed to purge car", err)
+			continue
+		}
+		if purged {
+			c.purgeCar(ctx, car.ID.Hex())
+			c.auditServices.Record(ctx, audit.Event{Type: audit.CarPurged, Email: car.Email, Actor: "system", Resource: car.ID.Hex()})
+		}
+	}
+}
+
+// purgeCar removes everything recorded about a purged car. The car is gone either way, records left behind can not
+// be reached.
+func (c *Services) purgeCar(ctx context.Context, carID string) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "purgeCar"))
+
+	if err := c.serviceRecordRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete service records", err)
+	}
+	if err := c.fuelRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete fuel logs", err)
+	}
+	if err := c.mileageRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete odometer readings", err)
+	}
+	if err := c.reminderRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete reminders", err)
+	}
+	if err := c.revisionRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete revisions", err)
+	}
+	c.deleteDocumentsForCar(ctx, carID)
+	c.deletePhotosForCar(ctx, carID)
+}
+
+// GetServiceRecords lists the car's service records, newest first, along with the totals for every year.
+func (c *Services) GetServiceRecords(ctx context.Context, session user.Session, carID string, year int) ([]ServiceRecord, []ServiceTotal, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetServiceRecords")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return nil, nil, err
+	}
+
+	records, err := c.serviceRecordRepository.List(carID, year)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list service records", err)
+		return nil, nil, err
+	}
+	totals, err := c.serviceRecordRepository.Totals(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to total service records", err)
+		return nil, nil, err
+	}
+	return records, totals, nil
+}
+
+func (c *Services) GetServiceRecord(ctx context.Context, session user.Session, carID string, recordID string) (ServiceRecord, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetServiceRecord")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return ServiceRecord{}, err
+	}
+	return c.serviceRecordRepository.Get(carID, recordID)
+}
+
+func (c *Services) CreateServiceRecord(ctx context.Context, session user.Session, carID string, body CreateServiceRecord) (ServiceRecord, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "CreateServiceRecord")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return ServiceRecord{}, err
+	}
+
+	record := ServiceRecord{
+		CarID:    carID,
+		Date:     body.Date,
+		Odometer: body.Odometer,
+		Type:     body.Type,
+		Cost:     body.Cost,
+		Currency: body.Currency,
+		Shop:     body.Shop,
+		Notes:    body.Notes,
+		Parts:    body.Parts,
+		Email:    session.Email,
+		Created:  time.Now(),
+	}
+	if record.Parts == nil {
+		record.Parts = []ServicePart{}
+	}
+	recordID, err := c.serviceRecordRepository.Save(record)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to save service record", err)
+		return ServiceRecord{}, err
+	}
+	record.ID = recordID
+	return record, nil
+}
+
+func (c *Services) UpdateServiceRecord(ctx context.Context, session user.Session, carID string, recordID string, body UpdateServiceRecord) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "UpdateServiceRecord")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+	return c.serviceRecordRepository.Update(carID, recordID, body)
+}
+
+func (c *Services) DeleteServiceRecord(ctx context.Context, session user.Session, carID string, recordID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeleteServiceRecord")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+	return c.serviceRecordRepository.Delete(carID, recordID)
+}
+
+// GetFuelLogs lists the car's fill ups and charges, oldest first, with the consumption worked out from them.
+func (c *Services) GetFuelLogs(ctx context.Context, session user.Session, carID string) ([]FuelLog, []FuelStats, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetFuelLogs")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return nil, nil, err
+	}
+
+	logs, err := c.fuelRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list fuel logs", err)
+		return nil, nil, err
+	}
+	return logs, ComputeFuelStats(logs), nil
+}
+
+// CreateFuelLog adds a fill up or charge. Its odometer has to fit between the logs before and after it, since
+// an odometer only goes up.
+func (c *Services) CreateFuelLog(ctx context.Context, session user.Session, carID string, body CreateFuelLog) (FuelLog, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "CreateFuelLog")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return FuelLog{}, err
+	}
+
+	before, after, err := c.fuelRepository.Neighbours(carID, body.Date)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to find neighbouring fuel logs", err)
+		return FuelLog{}, err
+	}
+	if before != nil && body.Odometer < before.Odometer {
+		return FuelLog{}, invalid("Error: Odometer is lower than the " + strconv.Itoa(before.Odometer) + " logged on " + before.Date.Format("2006-01-02"))
+	}
+	if after != nil && body.Odometer > after.Odometer {
+		return FuelLog{}, invalid("Error: Odometer is higher than the " + strconv.Itoa(after.Odometer) + " logged on " + after.Date.Format("2006-01-02"))
+	}
+
+	log := FuelLog{
+		CarID:    carID,
+		Kind:     body.Kind,
+		Date:     body.Date,
+		Odometer: body.Odometer,
+		Volume:   body.Volume,
+		KWh:      body.KWh,
+		Price:    body.Price,
+		Currency: body.Currency,
+		FullTank: body.FullTank,
+		Email:    session.Email,
+		Created:  time.Now(),
+	}
+	logID, err := c.fuelRepository.Save(log)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to save fuel log", err)
+		return FuelLog{}, err
+	}
+	log.ID = logID
+	return log, nil
+}
+
+func (c *Services) DeleteFuelLog(ctx context.Context, session user.Session, carID string, logID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeleteFuelLog")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+	return c.fuelRepository.Delete(carID, logID)
+}
+
+// GetMileage builds the car's odometer series from manual readings, service records and fuel logs.
+func (c *Services) GetMileage(ctx context.Context, session user.Session, carID string) (Mileage, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetMileage")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return Mileage{}, err
+	}
+	return c.getMileage(ctx, carID)
+}
+
+// getMileage reads the series without authorizing, for callers that already have.
+func (c *Services) getMileage(ctx context.Context, carID string) (Mileage, error) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "getMileage"))
+
+	readings, err := c.mileageRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list odometer readings", err)
+		return Mileage{}, err
+	}
+	records, err := c.serviceRecordRepository.List(carID, 0)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list service records", err)
+		return Mileage{}, err
+	}
+	logs, err := c.fuelRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list fuel logs", err)
+		return Mileage{}, err
+	}
+
+	readings = append(readings, ServiceReadings(records)...)
+	readings = append(readings, FuelReadings(logs)...)
+	return ComputeMileage(readings, time.Now()), nil
+}
+
+func (c *Services) CreateOdometerReading(ctx context.Context, session user.Session, carID string, body CreateOdometerReading) (OdometerReading, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "CreateOdometerReading")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return OdometerReading{}, err
+	}
+
+	reading := OdometerReading{
+		CarID:    carID,
+		Date:     body.Date,
+		Odometer: body.Odometer,
+		Source:   ReadingManual,
+		Email:    session.Email,
+		Created:  time.Now(),
+	}
+	readingID, err := c.mileageRepository.Save(reading)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to save odometer reading", err)
+		return OdometerReading{}, err
+	}
+	reading.ID = readingID
+	reading.SourceID = readingID.Hex()
+	return reading, nil
+}
+
+// DeleteOdometerReading removes a manual reading. Readings from service records and fuel logs go away with them.
+func (c *Services) DeleteOdometerReading(ctx context.Context, session user.Session, carID string, readingID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeleteOdometerReading")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+	return c.mileageRepository.Delete(carID, readingID)
+}
+
+// latestOdometer is the car's latest odometer reading, or zero when it has none.
+func (c *Services) latestOdometer(ctx context.Context, carID string) (int, error) {
+	mileage, err := c.getMileage(ctx, carID)
+	if err != nil {
+		return 0, err
+	}
+	if mileage.Latest == nil {
+		return 0, nil
+	}
+	return mileage.Latest.Odometer, nil
+}
+
+func (c *Services) GetReminders(ctx context.Context, session user.Session, carID string) ([]Reminder, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetReminders")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return []Reminder{}, err
+	}
+
+	reminders, err := c.reminderRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list reminders", err)
+		return []Reminder{}, err
+	}
+	odometer, err := c.latestOdometer(ctx, carID)
+	if err != nil {
+		return []Reminder{}, err
+	}
+	now := time.Now()
+	for i := range reminders {
+		reminders[i].Due = reminders[i].IsDue(now, odometer)
+	}
+	return reminders, nil
+}
+
+func (c *Services) CreateReminder(ctx context.Context, session user.Session, carID string, body CreateReminder) (Reminder, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "CreateReminder")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return Reminder{}, err
+	}
+
+	odometer, err := c.latestOdometer(ctx, carID)
+	if err != nil {
+		return Reminder{}, err
+	}
+	now := time.Now()
+	reminder := Reminder{
+		CarID:          carID,
+		Title:          body.Title,
+		IntervalMonths: body.IntervalMonths,
+		IntervalKm:     body.IntervalKm,
+		Status:         ReminderActive,
+		Email:          session.Email,
+		Created:        now,
+	}
+	reminder.scheduleFrom(now, odometer)
+	// A due point given in the body is the first one, the intervals take over once it is completed
+	if body.DueDate != nil {
+		reminder.DueDate = body.DueDate
+	}
+	if body.DueOdometer > 0 {
+		reminder.DueOdometer = body.DueOdometer
+	}
+
+	reminderID, err := c.reminderRepository.Save(reminder)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to save reminder", err)
+		return Reminder{}, err
+	}
+	reminder.ID = reminderID
+	reminder.Due = reminder.IsDue(now, odometer)
+	return reminder, nil
+}
+
+// SnoozeReminder holds off the reminder's emails until the time. Once the snooze ends it is emailed again if it
+// is still due.
+func (c *Services) SnoozeReminder(ctx context.Context, session user.Session, carID string, reminderID string, body SnoozeReminder) (Reminder, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "SnoozeReminder")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return Reminder{}, err
+	}
+
+	reminder, err := c.reminderRepository.Get(carID, reminderID)
+	if err != nil {
+		return Reminder{}, err
+	}
+	if reminder.Status != ReminderActive {
+		return Reminder{}, ErrReminderCompleted
+	}
+	if err := c.reminderRepository.Snooze(carID, reminder.ID, body.Until); err != nil {
+		c.logger.Warning(ctx, "failed to snooze reminder", err)
+		return Reminder{}, err
+	}
+	reminder.SnoozedUntil = &body.Until
+	return reminder, nil
+}
+
+// CompleteReminder records the maintenance as done. Reminders with an interval move on to their next due point
+// counted from the completion, others are finished.
+func (c *Services) CompleteReminder(ctx context.Context, session user.Session, carID string, reminderID string, body CompleteReminder) (Reminder, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "CompleteReminder")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return Reminder{}, err
+	}
+
+	reminder, err := c.reminderRepository.Get(carID, reminderID)
+	if err != nil {
+		return Reminder{}, err
+	}
+	if reminder.Status != ReminderActive {
+		return Reminder{}, ErrReminderCompleted
+	}
+
+	now := time.Now()
+	completion := ReminderCompletion{Date: now, Odometer: body.Odometer, Email: session.Email}
+	if body.Date != nil {
+		completion.Date = *body.Date
+	}
+	if completion.Odometer == 0 {
+		completion.Odometer, err = c.latestOdometer(ctx, carID)
+		if err != nil {
+			return Reminder{}, err
+		}
+	}
+
+	reminder.LastCompleted = &completion
+	reminder.SnoozedUntil = nil
+	if reminder.IntervalMonths > 0 || reminder.IntervalKm > 0 {
+		reminder.scheduleFrom(completion.Date, completion.Odometer)
+	} else {
+		reminder.Status = ReminderCompleted
+	}
+	if err := c.reminderRepository.Complete(carID, reminder); err != nil {
+		c.logger.Warning(ctx, "failed to complete reminder", err)
+		return Reminder{}, err
+	}
+	reminder.Due = reminder.IsDue(now, completion.Odometer)
+	return reminder, nil
+}
+
+func (c *Services) DeleteReminder(ctx context.Context, session user.Session, carID string, reminderID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeleteReminder")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+	return c.reminderRepository.Delete(carID, reminderID)
+}
+
+// StartReminderScheduler sends due reminders straight away and then every interval until the context is done.
+func (c *Services) StartReminderScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			c.SendDueReminders(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// SendDueReminders emails every due reminder that is not snoozed and has not been emailed for its due window.
+func (c *Services) SendDueReminders(ctx context.Context) {
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Cars")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "SendDueReminders")
+
+	now := time.Now()
+	odometers := map[string]int{}
+	err := c.reminderRepository.EachActive(now, func(reminder Reminder) error {
+		if reminder.NotifiedWindow == reminder.Window() {
+			return nil
+		}
+		odometer, ok := odometers[reminder.CarID]
+		if !ok && reminder.DueOdometer > 0 {
+			latest, err := c.latestOdometer(ctx, reminder.CarID)
+			if err != nil {
+				c.logger.Warning(ctx, "failed to get odometer for reminder", err)
+				return nil
+			}
+			odometers[reminder.CarID] = latest
+			odometer = latest
+		}
+		if reminder.IsDue(now, odometer) {
+			c.sendReminder(ctx, reminder, now)
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Error(ctx, "failed to check reminders", err)
+	}
+}
+
+// sendReminder claims the reminder's due window and emails it. Personal cars email the owner, organization cars
+// email whoever added the reminder as long as they can still see the car.
+func (c *Services) sendReminder(ctx context.Context, reminder Reminder, now time.Time) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "sendReminder"))
+
+	car, err := c.carsRepository.Get(reminder.CarID)
+	if err == ErrCarNotFound {
+		return
+	}
+	if err != nil {
+		c.logger.Warning(ctx, "failed to get car for reminder", err)
+		return
+	}
+	toEmail := car.Email
+	if car.OrganizationID != "" {
+		if !c.canView(ctx, user.Session{Email: reminder.Email}, car.Owner()) {
+			return
+		}
+		toEmail = reminder.Email
+	}
+
+	window := reminder.Window()
+	claimed, err := c.reminderRepository.MarkNotified(reminder.ID, window, now)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to mark reminder notified", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	recipientName := "there"
+	found, recipient, err := c.userRepository.GetUserByEmail(toEmail)
+	if err == nil && found {
+		recipientName = recipient.Greeting()
+	}
+	err = maintenancereminderemail.SendMaintenanceReminderEmail(recipientName, toEmail, reminder.Title, car.Description(), reminder.DueDescription(), reminder.CarID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to send maintenance reminder email", err)
+		if err := c.reminderRepository.UnmarkNotified(reminder.ID, window); err != nil {
+			c.logger.Warning(ctx, "failed to unmark reminder notified", err)
+		}
+	}
+}
+
+func (c *Services) GetDocuments(ctx context.Context, session user.Session, carID string, documentType string) ([]Document, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetDocuments")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return []Document{}, err
+	}
+
+	documents, err := c.documentRepository.List(carID, documentType)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list documents", err)
+		return []Document{}, err
+	}
+	now := time.Now()
+	for i := range documents {
+		documents[i].Expired = documents[i].Expires != nil && now.After(*documents[i].Expires)
+	}
+	return documents, nil
+}
+
+// UploadDocument stores the file and the document. The content type is sniffed from the file and the size is
+// checked while it is stored. A document with an expiry gets a renewal reminder, replacing the reminders of older
+// documents of the same type.
+func (c *Services) UploadDocument(ctx context.Context, session user.Session, carID string, body CreateDocument, content io.Reader) (Document, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "UploadDocument")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return Document{}, err
+	}
+
+	contentType, content, err := SniffDocument(content)
+	if err != nil {
+		return Document{}, err
+	}
+
+	now := time.Now()
+	document := Document{
+		ID:          primitive.NewObjectID(),
+		CarID:       carID,
+		Type:        body.Type,
+		Name:        body.Name,
+		ContentType: contentType,
+		Expires:     body.Expires,
+		Email:       session.Email,
+		Created:     now,
+	}
+	document.StorageKey = documentStorageKey(carID, document.ID)
+
+	// One byte over the limit is enough to know the file is too large
+	size, err := c.fileStorage.Save(document.StorageKey, io.LimitReader(content, MaxDocumentBytes+1))
+	if err != nil {
+		c.logger.Warning(ctx, "failed to store document", err)
+		return Document{}, err
+	}
+	if size > MaxDocumentBytes {
+		c.deleteFile(ctx, document.StorageKey)
+		return Document{}, ErrDocumentTooLarge
+	}
+	document.Size = size
+
+	if err := c.documentRepository.Save(document); err != nil {
+		c.logger.Warning(ctx, "failed to save document", err)
+		c.deleteFile(ctx, document.StorageKey)
+		return Document{}, err
+	}
+
+	if document.Expires != nil {
+		c.scheduleRenewal(ctx, document, now)
+	}
+	return document, nil
+}
+
+// scheduleRenewal adds the document's renewal reminder. Older documents of the same type have been replaced, so
+// their reminders go.
+func (c *Services) scheduleRenewal(ctx context.Context, document Document, now time.Time) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "scheduleRenewal"))
+
+	previous, err := c.documentRepository.List(document.CarID, document.Type)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list previous documents", err)
+		return
+	}
+	previousIDs := []string{}
+	for _, previousDocument := range previous {
+		if previousDocument.ID != document.ID {
+			previousIDs = append(previousIDs, previousDocument.ID.Hex())
+		}
+	}
+	if err := c.reminderRepository.DeleteForDocuments(document.CarID, previousIDs); err != nil {
+		c.logger.Warning(ctx, "failed to delete previous renewal reminders", err)
+	}
+
+	if _, err := c.reminderRepository.Save(document.renewalReminder(now)); err != nil {
+		c.logger.Warning(ctx, "failed to save renewal reminder", err)
+	}
+}
+
+// DownloadDocument returns the document and its file, which the caller closes.
+func (c *Services) DownloadDocument(ctx context.Context, session user.Session, carID string, documentID string) (Document, io.ReadCloser, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DownloadDocument")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return Document{}, nil, err
+	}
+
+	document, err := c.documentRepository.Get(carID, documentID)
+	if err != nil {
+		return Document{}, nil, err
+	}
+	file, err := c.fileStorage.Open(document.StorageKey)
+	if err == storage.ErrNotFound {
+		c.logger.Error(ctx, "document file is missing", err)
+		return Document{}, nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		c.logger.Warning(ctx, "failed to open document", err)
+		return Document{}, nil, err
+	}
+	return document, file, nil
+}
+
+func (c *Services) DeleteDocument(ctx context.Context, session user.Session, carID string, documentID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeleteDocument")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+
+	document, err := c.documentRepository.Get(carID, documentID)
+	if err != nil {
+		return err
+	}
+	if err := c.documentRepository.Delete(carID, document.ID); err != nil {
+		return err
+	}
+	c.deleteFile(ctx, document.StorageKey)
+	if err := c.reminderRepository.DeleteForDocuments(carID, []string{documentID}); err != nil {
+		c.logger.Warning(ctx, "failed to delete renewal reminder", err)
+	}
+	return nil
+}
+
+// deleteDocumentsForCar removes the files and documents of a car, used when the car is purged.
+func (c *Services) deleteDocumentsForCar(ctx context.Context, carID string) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "deleteDocumentsForCar"))
+
+	documents, err := c.documentRepository.List(carID, "")
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list documents", err)
+		return
+	}
+	for _, document := range documents {
+		c.deleteFile(ctx, document.StorageKey)
+	}
+	if err := c.documentRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete documents", err)
+	}
+}
+
+// deleteFile logs rather than fails, a file left behind only takes up space.
+func (c *Services) deleteFile(ctx context.Context, key string) {
+	if err := c.fileStorage.Delete(key); err != nil {
+		c.logger.Warning(ctx, "failed to delete file", err)
+	}
+}
+
+func (c *Services) GetPhotos(ctx context.Context, session user.Session, carID string) ([]Photo, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetPhotos")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return []Photo{}, err
+	}
+
+	photos, err := c.photoRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list photos", err)
+		return []Photo{}, err
+	}
+	for i := range photos {
+		photos[i].setURLs()
+	}
+	return photos, nil
+}
+
+// UploadPhoto stores the photo's variants, made without its EXIF data. A car's first photo is its primary photo.
+func (c *Services) UploadPhoto(ctx context.Context, session user.Session, carID string, content io.Reader) (Photo, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "UploadPhoto")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return Photo{}, err
+	}
+
+	count, err := c.photoRepository.Count(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to count photos", err)
+		return Photo{}, err
+	}
+	if count >= maxCarPhotos {
+		return Photo{}, ErrTooManyPhotos
+	}
+
+	// One byte over the limit is enough to know the file is too large
+	data, err := io.ReadAll(io.LimitReader(content, MaxPhotoBytes+1))
+	if err != nil {
+		return Photo{}, err
+	}
+	if len(data) > MaxPhotoBytes {
+		return Photo{}, ErrPhotoTooLarge
+	}
+	contentType, variants, err := ProcessPhoto(data)
+	if err != nil {
+		return Photo{}, err
+	}
+
+	photo := Photo{
+		ID:          primitive.NewObjectID(),
+		CarID:       carID,
+		ContentType: contentType,
+		Variants:    []PhotoVariant{},
+		Primary:     count == 0,
+		Email:       session.Email,
+		Created:     time.Now(),
+	}
+	for _, variant := range variants {
+		if _, err := c.fileStorage.Save(photoStorageKey(carID, photo.ID, variant.Name), bytes.NewReader(variant.Data)); err != nil {
+			c.logger.Warning(ctx, "failed to store photo", err)
+			c.deletePhotoFiles(ctx, photo)
+			return Photo{}, err
+		}
+		photo.Variants = append(photo.Variants, variant.PhotoVariant)
+	}
+
+	if err := c.photoRepository.Save(photo); err != nil {
+		c.logger.Warning(ctx, "failed to save photo", err)
+		c.deletePhotoFiles(ctx, photo)
+		return Photo{}, err
+	}
+	if photo.Primary {
+		carPhoto := photo.carPhoto()
+		if err := c.carsRepository.SetPrimaryPhoto(carID, &carPhoto); err != nil {
+			c.logger.Warning(ctx, "failed to set primary photo", err)
+		}
+	}
+	photo.setURLs()
+	return photo, nil
+}
+
+// DownloadPhoto returns a variant of the photo and its file, which the caller closes.
+func (c *Services) DownloadPhoto(ctx context.Context, session user.Session, carID string, photoID string, variantName string) (Photo, PhotoVariant, io.ReadCloser, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DownloadPhoto")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionViewCars); err != nil {
+		return Photo{}, PhotoVariant{}, nil, err
+	}
+
+	photo, err := c.photoRepository.Get(carID, photoID)
+	if err != nil {
+		return Photo{}, PhotoVariant{}, nil, err
+	}
+	variant, ok := photo.Variant(variantName)
+	if !ok {
+		return Photo{}, PhotoVariant{}, nil, ErrPhotoNotFound
+	}
+	file, err := c.fileStorage.Open(photoStorageKey(carID, photo.ID, variant.Name))
+	if err == storage.ErrNotFound {
+		c.logger.Error(ctx, "photo file is missing", err)
+		return Photo{}, PhotoVariant{}, nil, ErrPhotoNotFound
+	}
+	if err != nil {
+		c.logger.Warning(ctx, "failed to open photo", err)
+		return Photo{}, PhotoVariant{}, nil, err
+	}
+	return photo, variant, file, nil
+}
+
+func (c *Services) SetPrimaryPhoto(ctx context.Context, session user.Session, carID string, photoID string) (Photo, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "SetPrimaryPhoto")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return Photo{}, err
+	}
+
+	photo, err := c.photoRepository.Get(carID, photoID)
+	if err != nil {
+		return Photo{}, err
+	}
+	if err := c.photoRepository.SetPrimary(carID, photo.ID); err != nil {
+		c.logger.Warning(ctx, "failed to set primary photo", err)
+		return Photo{}, err
+	}
+	carPhoto := photo.carPhoto()
+	if err := c.carsRepository.SetPrimaryPhoto(carID, &carPhoto); err != nil {
+		c.logger.Warning(ctx, "failed to set primary photo on car", err)
+		return Photo{}, err
+	}
+	photo.Primary = true
+	photo.setURLs()
+	return photo, nil
+}
+
+// DeletePhoto removes the photo and its files. When it was the primary photo the oldest photo left takes over.
+func (c *Services) DeletePhoto(ctx context.Context, session user.Session, carID string, photoID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeletePhoto")
+
+	if _, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionEditCars); err != nil {
+		return err
+	}
+
+	photo, err := c.photoRepository.Get(carID, photoID)
+	if err != nil {
+		return err
+	}
+	if err := c.photoRepository.Delete(carID, photo.ID); err != nil {
+		return err
+	}
+	c.deletePhotoFiles(ctx, photo)
+	if !photo.Primary {
+		return nil
+	}
+
+	remaining, err := c.photoRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list photos", err)
+		return nil
+	}
+	var carPhoto *CarPhoto
+	if len(remaining) > 0 {
+		if err := c.photoRepository.SetPrimary(carID, remaining[0].ID); err != nil {
+			c.logger.Warning(ctx, "failed to set primary photo", err)
+		}
+		next := remaining[0].carPhoto()
+		carPhoto = &next
+	}
+	if err := c.carsRepository.SetPrimaryPhoto(carID, carPhoto); err != nil {
+		c.logger.Warning(ctx, "failed to set primary photo on car", err)
+	}
+	return nil
+}
+
+func (c *Services) deletePhotoFiles(ctx context.Context, photo Photo) {
+	for _, size := range photoVariantSizes {
+		c.deleteFile(ctx, photoStorageKey(photo.CarID, photo.ID, size.Name))
+	}
+}
+
+// deletePhotosForCar removes the files and photos of a car, used when the car is purged.
+func (c *Services) deletePhotosForCar(ctx context.Context, carID string) {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "deletePhotosForCar"))
+
+	photos, err := c.photoRepository.List(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list photos", err)
+		return
+	}
+	for _, photo := range photos {
+		c.deletePhotoFiles(ctx, photo)
+	}
+	if err := c.photoRepository.DeleteForCar(carID); err != nil {
+		c.logger.Warning(ctx, "failed to delete photos", err)
+	}
+}
+
+// StartImport checks the importer can add cars for the owner and then adds the rows in the background. The
+// returned job can be polled with GetImport to follow its progress.
+func (c *Services) StartImport(ctx context.Context, session user.Session, body ImportCars) (ImportJob, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "StartImport")
+
+	owner := Owner{Email: session.Email, OrganizationID: body.OrganizationID}
+	if err := c.authorize(ctx, session, owner, organizations.PermissionEditCars); err != nil {
+		return ImportJob{}, err
+	}
+
+	job := ImportJob{
+		Email:          session.Email,
+		OrganizationID: body.OrganizationID,
+		Format:         body.Format,
+		DryRun:         body.DryRun,
+		Status:         ImportRunning,
+		TotalRows:      len(body.Rows),
+		Errors:         []ImportRowError{},
+		Created:        time.Now(),
+	}
+	jobID, err := c.importRepository.Save(job)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to save import", err)
+		return ImportJob{}, err
+	}
+	job.ID = jobID
+
+	go c.runImport(ctx, session, owner, job, body.Rows)
+	return job, nil
+}
+
+// importProgressEvery is how many rows are added between saving the job's progress.
+const importProgressEvery = 100
+
+func (c *Services) runImport(ctx context.Context, session user.Session, owner Owner, job ImportJob, rows []ImportRow) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "runImport")
+
+	// Nobody is waiting on the import, so a panic would kill the server and leave the job running forever
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error(ctx, "import panicked", fmt.Errorf("%v", r))
+			finished := time.Now()
+			job.Status = ImportFailed
+			job.Finished = &finished
+			if err := c.importRepository.UpdateProgress(job); err != nil {
+				c.logger.Error(ctx, "failed to mark import as failed", err)
+			}
+		}
+	}()
+
+	seenVINs := map[string]int{}
+	for _, row := range rows {
+		if err := c.importRow(ctx, session, owner, &job, row, seenVINs); err != nil {
+			job.addError(row.Row, err.Error())
+		}
+		job.ProcessedRows++
+
+		if job.ProcessedRows%importProgressEvery == 0 {
+			if err := c.importRepository.UpdateProgress(job); err != nil {
+				c.logger.Warning(ctx, "failed to save import progress", err)
+			}
+		}
+	}
+
+	finished := time.Now()
+	job.Status = ImportCompleted
+	job.Finished = &finished
+	if err := c.importRepository.UpdateProgress(job); err != nil {
+		c.logger.Error(ctx, "failed to finish import", err)
+	}
+}
+
+// importRow checks a row the same way as creating a single car and saves it unless the import is a dry run.
+func (c *Services) importRow(ctx context.Context, session user.Session, owner Owner, job *ImportJob, row ImportRow, seenVINs map[string]int) error {
+	if row.Error != "" {
+		return errors.New(row.Error)
+	}
+	body := row.Car
+	body.OrganizationID = owner.OrganizationID
+	if err := body.Valid(); err != nil {
+		return err
+	}
+
+	if body.VIN != "" {
+		if firstRow, seen := seenVINs[body.VIN]; seen {
+			return invalid("Error: The VIN is already used on row " + strconv.Itoa(firstRow))
+		}
+		seenVINs[body.VIN] = row.Row
+	}
+
+	if job.DryRun {
+		if body.VIN != "" {
+			exists, err := c.carsRepository.VINExists(owner, body.VIN)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrDuplicateVIN
+			}
+		}
+		job.CreatedCount++
+		return nil
+	}
+
+	car, err := c.carsRepository.Save(newCar(session, owner, body))
+	if err != nil {
+		return err
+	}
+	carID := car.ID.Hex()
+	job.CreatedCount++
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarCreated, Email: session.Email, Resource: carID, Metadata: map[string]string{"importId": job.ID.Hex()}})
+	c.recordRevision(ctx, session, carID, nil, snapshotOf(car), 0)
+	return nil
+}
+
+// Export calls fn with every car matching the query, after checking the user can view the owner's cars.
+func (c *Services) Export(ctx context.Context, session user.Session, query ListCarQuery, format string, fn func(Car) error) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Export")
+
+	owner := Owner{Email: session.Email, OrganizationID: query.OrganizationID}
+	if err := c.authorize(ctx, session, owner, organizations.PermissionViewCars); err != nil {
+		return err
+	}
+
+	count := 0
+	err := c.carsRepository.Each(owner, query, func(car Car) error {
+		count++
+		return fn(car)
+	})
+	if err != nil {
+		c.logger.Warning(ctx, "failed to export cars", err)
+		return err
+	}
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarsExported, Email: session.Email, Resource: owner.Key(), Metadata: map[string]string{"format": format, "count": strconv.Itoa(count)}})
+	return nil
+}
+
+func (c *Services) GetImport(ctx context.Context, session user.Session, jobID string) (ImportJob, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetImport")
+
+	job, err := c.importRepository.Get(session.Email, jobID)
+	if err != nil {
+		if err != ErrImportNotFound {
+			c.logger.Warning(ctx, "failed to get import", err)
+		}
+		return ImportJob{}, err
+	}
+	return job, nil
+}
+
+// InitiateTransfer starts moving the car to another user. The recipient is emailed a code to accept or decline.
+// Giving a car away needs the same permission as deleting it.
+func (c *Services) InitiateTransfer(ctx context.Context, session user.Session, carID string, body CreateTransfer) (CarTransfer, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "InitiateTransfer")
+
+	car, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionDeleteCars)
+	if err != nil {
+		return CarTransfer{}, err
+	}
+
+	toEmail := body.GetFormattedEmail()
+	if car.OrganizationID == "" && toEmail == car.Email {
+		return CarTransfer{}, invalid("Error: Car already belongs to this email")
+	}
+
+	// Replace any transfer that was already waiting on a response
+	err = c.transferRepository.CancelPending(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to cancel pending transfers", err)
+		return CarTransfer{}, err
+	}
+
+	now := time.Now()
+	transfer := CarTransfer{
+		CarID:     carID,
+		FromEmail: session.Email,
+		ToEmail:   toEmail,
+		Code:      uuid.New().String(),
+		Status:    TransferPending,
+		Created:   now,
+		Expiry:    now.AddDate(0, 0, 7), // Expires in 7 days
+	}
+	transferID, err := c.transferRepository.Save(transfer)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to save transfer", err)
+		return CarTransfer{}, err
+	}
+
+	senderName := session.Email
+	found, sender, err := c.userRepository.GetUserByEmail(session.Email)
+	if err == nil && found && sender.Name != "" {
+		senderName = sender.Name
+	}
+	recipientName := "there"
+	found, recipient, err := c.userRepository.GetUserByEmail(toEmail)
+	if err == nil && found {
+		recipientName = recipient.Greeting()
+	}
+	err = cartransferemail.SendCarTransferEmail(recipientName, toEmail, senderName, car.Description(), transfer.Code)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to send car transfer email", err)
+		return CarTransfer{}, err
+	}
+
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarTransferRequested, Email: session.Email, Resource: carID, Metadata: map[string]string{"transferId": transferID, "toEmail": toEmail}})
+
+	transfer.ID, _ = primitive.ObjectIDFromHex(transferID)
+	return transfer, nil
+}
+
+// CancelTransfer cancels the pending transfer on the car, if there is one.
+func (c *Services) CancelTransfer(ctx context.Context, session user.Session, carID string) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "CancelTransfer")
+
+	_, err := c.getAuthorizedCar(ctx, session, carID, organizations.PermissionDeleteCars)
+	if err != nil {
+		return err
+	}
+
+	err = c.transferRepository.CancelPending(carID)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to cancel pending transfers", err)
+		return err
+	}
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarTransferCancelled, Email: session.Email, Resource: carID})
+	return nil
+}
+
+// GetTransfers lists pending transfers sent to or from the signed in user.
+func (c *Services) GetTransfers(ctx context.Context, session user.Session) ([]CarTransfer, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetTransfers")
+
+	transfers, err := c.transferRepository.ListPending(session.Email)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to list transfers", err)
+		return []CarTransfer{}, err
+	}
+	return transfers, nil
+}
+
+// AcceptTransfer moves the car into the signed in user's account. Only the email the transfer was sent to can
+// accept it.
+func (c *Services) AcceptTransfer(ctx context.Context, session user.Session, body RespondTransfer) (Car, error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "AcceptTransfer")
+
+	transfer, err := c.getOpenTransfer(ctx, session, body.Code)
+	if err != nil {
+		return Car{}, err
+	}
+
+	car, err := c.carsRepository.Get(transfer.CarID)
+	if err != nil {
+		return Car{}, err
+	}
+
+	// Claim the transfer first so a second accept (or a decline) can't also go through
+	claimed, err := c.transferRepository.Respond(body.Code, TransferAccepted)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to accept transfer", err)
+		return Car{}, err
+	}
+	if !claimed {
+		return Car{}, ErrTransferNotFound
+	}
+
+	// The claim and the move are separate writes, so a failed move has to give the claim back
+	moved, err := c.carsRepository.Transfer(car, session.Email, transfer.ID.Hex())
+	if err != nil {
+		c.logger.Error(ctx, "failed to move car to new owner", err)
+		if err := c.transferRepository.Unaccept(body.Code, TransferPending); err != nil {
+			c.logger.Error(ctx, "failed to reopen transfer", err)
+		}
+		return Car{}, err
+	}
+	if !moved {
+		c.logger.Warning(ctx, "car changed owner before transfer was accepted", ErrTransferConflict)
+		if err := c.transferRepository.Unaccept(body.Code, TransferCancelled); err != nil {
+			c.logger.Error(ctx, "failed to cancel transfer", err)
+		}
+		return Car{}, ErrTransferConflict
+	}
+
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarTransferAccepted, Email: session.Email, Resource: transfer.CarID, Metadata: map[string]string{"transferId": transfer.ID.Hex(), "fromEmail": car.Email}})
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarTransferAccepted, Email: transfer.FromEmail, Actor: session.Email, Resource: transfer.CarID, Metadata: map[string]string{"transferId": transfer.ID.Hex(), "toEmail": session.Email}})
+
+	return c.carsRepository.Get(transfer.CarID)
+}
+
+// DeclineTransfer leaves the car with its current owner.
+func (c *Services) DeclineTransfer(ctx context.Context, session user.Session, body RespondTransfer) error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "DeclineTransfer")
+
+	transfer, err := c.getOpenTransfer(ctx, session, body.Code)
+	if err != nil {
+		return err
+	}
+
+	declined, err := c.transferRepository.Respond(body.Code, TransferDeclined)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to decline transfer", err)
+		return err
+	}
+	if !declined {
+		return ErrTransferNotFound
+	}
+
+	c.auditServices.Record(ctx, audit.Event{Type: audit.CarTransferDeclined, Email: transfer.FromEmail, Actor: session.Email, Resource: transfer.CarID, Metadata: map[string]string{"transferId": transfer.ID.Hex()}})
+	return nil
+}
+
+func (c *Services) getOpenTransfer(ctx context.Context, session user.Session, code string) (CarTransfer, error) {
+	found, transfer, err := c.transferRepository.GetOpen(code)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to get transfer", err)
+		return CarTransfer{}, err
+	}
+	if !found || transfer.ToEmail != session.Email {
+		return CarTransfer{}, ErrTransferNotFound
+	}
+	return transfer, nil
+}
+
+// getAuthorizedCar looks up the car and checks the session is allowed the permission on it. Cars the user can't
+// see come back as not found so the IDs of other users' cars are not leaked.
+func (c *Services) getAuthorizedCar(ctx context.Context, session user.Session, carID string, permission string) (Car, error) {
+	car, err := c.carsRepository.Get(carID)
+	if err != nil {
+		return Car{}, err
+	}
+	err = c.authorize(ctx, session, car.Owner(), permission)
+	if err == ErrNotPermitted && !c.canView(ctx, session, car.Owner()) {
+		return Car{}, ErrCarNotFound
+	}
+	if err != nil {
+		return Car{}, err
+	}
+	return car, nil
+}
+
+// authorize checks the session has the permission on cars belonging to the owner. Personal cars can only be
+// used by their owner, organization cars depend on the member's role.
+func (c *Services) authorize(ctx context.Context, session user.Session, owner Owner, permission string) error {
+	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "authorize"))
+
+	if owner.OrganizationID == "" {
+		if owner.Email != session.Email {
+			return ErrNotPermitted
+		}
+		return nil
+	}
+
+	found, membership, err := c.organizationsRepository.GetMembership(owner.OrganizationID, session.Email)
+	if err != nil {
+		c.logger.Warning(ctx, "failed to get organization membership", err)
+		return err
+	}
+	if !found || !organizations.RoleHasPermission(membership.Role, permission) {
+		return ErrNotPermitted
+	}
+	return nil
+}
+
+func (c *Services) canView(ctx context.Context, session user.Session, owner Owner) bool {
+	return c.authorize(ctx, session, owner, organizations.PermissionViewCars) == nil
+}
@@ -0,0 +1,42 @@
+package cars
+
+// statsYearBucket is how many model years are counted together.
+const statsYearBucket = 5
+
+// CarStats are counts and totals over all of an owner's cars, for a dashboard. Cars in the trash are left out.
+type CarStats struct {
+	Total       int          `json:"total" bson:"total"`
+	ByMake      []StatCount  `json:"byMake" bson:"byMake"`
+	ByModel     []ModelCount `json:"byModel" bson:"byModel"`
+	ByYear      []YearBucket `json:"byYear" bson:"byYear"`
+	ByStatus    []StatCount  `json:"byStatus" bson:"byStatus"`
+	AverageAge  float64      `json:"averageAge" bson:"averageAge"`   // In years, from the model year
+	Maintenance []SpendTotal `json:"maintenance" bson:"maintenance"` // From the service records
+	Fuel        []SpendTotal `json:"fuel" bson:"fuel"`               // From the fuel and charging logs
+}
+
+// StatCount is how many cars have a value, most common first.
+type StatCount struct {
+	Value string `json:"value" bson:"value"`
+	Count int    `json:"count" bson:"count"`
+}
+
+type ModelCount struct {
+	Make  string `json:"make" bson:"make"`
+	Model string `json:"model" bson:"model"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// YearBucket is how many cars have a model year from From to To, both included.
+type YearBucket struct {
+	From  int `json:"from" bson:"from"`
+	To    int `json:"to" bson:"to"`
+	Count int `json:"count" bson:"count"`
+}
+
+// SpendTotal is what was spent in a currency, in its minor unit. Currencies are never added together.
+type SpendTotal struct {
+	Currency string `json:"currency,omitempty" bson:"currency,omitempty"`
+	Count    int    `json:"count" bson:"count"`
+	Total    int64  `json:"total" bson:"total"`
+}
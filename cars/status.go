@@ -0,0 +1,66 @@
+package cars
+
+import (
+	"time"
+)
+
+// Car statuses. Cars made before statuses existed have an empty status, which is treated as active.
+const (
+	StatusActive    = "active"
+	StatusInService = "in-service"
+	StatusForSale   = "for-sale"
+	StatusSold      = "sold"
+	StatusScrapped  = "scrapped"
+)
+
+// statusTransitions lists the statuses each status can move to. Scrapped cars can not come back and a sold
+// car can only be scrapped, since it is then no longer in use by its owner.
+var statusTransitions = map[string][]string{
+	StatusActive:    {StatusInService, StatusForSale, StatusSold, StatusScrapped},
+	StatusInService: {StatusActive, StatusForSale, StatusScrapped},
+	StatusForSale:   {StatusActive, StatusInService, StatusSold, StatusScrapped},
+	StatusSold:      {StatusScrapped},
+	StatusScrapped:  {},
+}
+
+const maxStatusReasonLength = 500
+
+// StatusChange is one move between statuses, kept on the car oldest first.
+type StatusChange struct {
+	From    string    `json:"from" bson:"from"`
+	To      string    `json:"to" bson:"to"`
+	Reason  string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	Email   string    `json:"email" bson:"email"` // Who made the change
+	Changed time.Time `json:"changed" bson:"changed"`
+}
+
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether a car in the from status can be moved to the to status.
+func CanTransition(from string, to string) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentStatus is the car's status with the empty status of older cars read as active.
+func (c *Car) CurrentStatus() string {
+	if c.Status == "" {
+		return StatusActive
+	}
+	return c.Status
+}
+
+// storedStatuses is what a status can look like in the database, which includes the empty status of older cars.
+func storedStatuses(status string) []string {
+	if status == StatusActive {
+		return []string{StatusActive, ""}
+	}
+	return []string{status}
+}
@@ -0,0 +1,97 @@
+package cars
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type TransferRepository struct {
+	db                 *mongo.Database
+	transferCollection string
+}
+
+func NewInstanceOfTransferRepository(db *mongo.Database) TransferRepository {
+	return TransferRepository{db: db, transferCollection: "carTransfers"}
+}
+
+func (r *TransferRepository) Save(transfer CarTransfer) (string, error) {
+	insertResult, err := r.db.Collection(r.transferCollection).InsertOne(context.TODO(), transfer)
+	if err != nil {
+		return "", err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetOpen finds a pending transfer that has not expired.
+func (r *TransferRepository) GetOpen(code string) (bool, CarTransfer, error) {
+	var transfer CarTransfer
+	filter := bson.M{
+		"code":   code,
+		"status": TransferPending,
+		"expiry": bson.M{"$gt": time.Now()},
+	}
+	err := r.db.Collection(r.transferCollection).FindOne(context.TODO(), filter).Decode(&transfer)
+	if err == mongo.ErrNoDocuments {
+		return false, CarTransfer{}, nil
+	}
+	if err != nil {
+		return false, CarTransfer{}, err
+	}
+	return true, transfer, nil
+}
+
+// ListPending lists transfers that are waiting on a response, sent to or from the email.
+func (r *TransferRepository) ListPending(email string) ([]CarTransfer, error) {
+	filter := bson.M{
+		"$or":    []bson.M{{"fromEmail": email}, {"toEmail": email}},
+		"status": TransferPending,
+		"expiry": bson.M{"$gt": time.Now()},
+	}
+	cursor, err := r.db.Collection(r.transferCollection).Find(context.TODO(), filter)
+	if err != nil {
+		return []CarTransfer{}, err
+	}
+	transfers := []CarTransfer{}
+	if err := cursor.All(context.TODO(), &transfers); err != nil {
+		return []CarTransfer{}, err
+	}
+	return transfers, nil
+}
+
+// Respond moves a pending transfer to a final status. Returns false if the transfer was no longer pending, so
+// only one response can ever win.
+func (r *TransferRepository) Respond(code string, status string) (bool, error) {
+	filter := bson.M{"code": code, "status": TransferPending, "expiry": bson.M{"$gt": time.Now()}}
+	update := bson.M{"$set": bson.M{"status": status, "responded": time.Now()}}
+	result, err := r.db.Collection(r.transferCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Unaccept moves a transfer claimed with Respond back out of accepted, when the car could not be moved. Back to
+// pending lets the recipient try again or decline, cancelled ends a transfer that can no longer go through.
+func (r *TransferRepository) Unaccept(code string, status string) error {
+	filter := bson.M{"code": code, "status": TransferAccepted}
+	update := bson.M{"$set": bson.M{"status": status, "responded": time.Now()}}
+	if status == TransferPending {
+		update = bson.M{"$set": bson.M{"status": status}, "$unset": bson.M{"responded": ""}}
+	}
+	_, err := r.db.Collection(r.transferCollection).UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+// CancelPending cancels any pending transfers for the car. A car can only have one pending transfer at a time.
+func (r *TransferRepository) CancelPending(carID string) error {
+	filter := bson.M{"carId": carID, "status": TransferPending}
+	update := bson.M{"$set": bson.M{"status": TransferCancelled, "responded": time.Now()}}
+	_, err := r.db.Collection(r.transferCollection).UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
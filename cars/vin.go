@@ -0,0 +1,151 @@
+package cars
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const vinLength = 17
+
+var (
+	ErrInvalidVIN   = invalid("Error: VIN must be 17 letters and digits, without I, O or Q")
+	ErrVINCheck     = invalid("Error: VIN check digit does not match, please check the VIN for typos")
+	ErrDuplicateVIN = errors.New("Error: A car with this VIN is already registered")
+)
+
+// vinValues is the ISO 3779 transliteration of each VIN character to the number used for the check digit.
+// I, O and Q are never used since they look like 1 and 0.
+var vinValues = map[rune]int{
+	'0': 0, '1': 1, '2': 2, '3': 3, '4': 4, '5': 5, '6': 6, '7': 7, '8': 8, '9': 9,
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+// vinWeights is the weight of each position in the check digit sum. The check digit itself (position 9) has
+// no weight.
+var vinWeights = [vinLength]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// modelYearCodes is the order of the model year codes in position 10. They repeat every 30 years starting
+// from 1980.
+const modelYearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// DecodedVIN is what can be read from a VIN without looking it up online.
+type DecodedVIN struct {
+	WMI          string `json:"wmi"` // World manufacturer identifier, the first three characters
+	Region       string `json:"region"`
+	Manufacturer string `json:"manufacturer,omitempty"` // Empty when the WMI is not in the bundled table
+	ModelYear    int    `json:"modelYear,omitempty"`    // Zero when the VIN does not carry a model year
+}
+
+// NormalizeVIN upper cases the VIN and removes spaces and dashes people add when copying it.
+func NormalizeVIN(vin string) string {
+	vin = strings.ToUpper(strings.TrimSpace(vin))
+	vin = strings.ReplaceAll(vin, " ", "")
+	return strings.ReplaceAll(vin, "-", "")
+}
+
+// ValidateVIN checks the characters and the check digit of a normalized VIN. The check digit is only required
+// for cars built for North America and China, other regions often use position 9 for something else.
+func ValidateVIN(vin string) error {
+	if len(vin) != vinLength {
+		return ErrInvalidVIN
+	}
+	sum := 0
+	for i, char := range vin {
+		value, ok := vinValues[char]
+		if !ok {
+			return ErrInvalidVIN
+		}
+		sum += value * vinWeights[i]
+	}
+	if !usesCheckDigit(vin) {
+		return nil
+	}
+
+	check := byte('0' + sum%11)
+	if sum%11 == 10 {
+		check = 'X'
+	}
+	if vin[8] != check {
+		return ErrVINCheck
+	}
+	return nil
+}
+
+func usesCheckDigit(vin string) bool {
+	return (vin[0] >= '1' && vin[0] <= '5') || vin[0] == 'L'
+}
+
+// DecodeVIN reads the region, manufacturer and model year of a valid VIN.
+func DecodeVIN(vin string) DecodedVIN {
+	wmi := vin[:3]
+	decoded := DecodedVIN{
+		WMI:          wmi,
+		Region:       vinRegion(vin[0]),
+		Manufacturer: wmiManufacturers[wmi],
+	}
+	if usesCheckDigit(vin) {
+		// The same markets that require the check digit require the model year code
+		decoded.ModelYear = vinModelYear(vin)
+	}
+	return decoded
+}
+
+func vinRegion(code byte) string {
+	switch {
+	case code >= 'A' && code <= 'H':
+		return "Africa"
+	case code >= 'J' && code <= 'R':
+		return "Asia"
+	case code >= 'S' && code <= 'Z':
+		return "Europe"
+	case code >= '1' && code <= '5':
+		return "North America"
+	case code == '6' || code == '7':
+		return "Oceania"
+	default:
+		return "South America"
+	}
+}
+
+// vinModelYear reads position 10. The code repeats every 30 years, so position 7 picks the cycle the way
+// North American VINs do: a digit there means 1980 to 2009 and a letter means 2010 to 2039.
+func vinModelYear(vin string) int {
+	index := strings.IndexByte(modelYearCodes, vin[9])
+	if index == -1 {
+		return 0
+	}
+	year := 1980 + index
+	if vin[6] < '0' || vin[6] > '9' {
+		year += 30
+	}
+	return year
+}
+
+// Check makes sure a make and year agree with what the VIN says. Parts of the VIN that could not be decoded
+// are not checked.
+func (d DecodedVIN) Check(make string, year int) error {
+	if !d.MatchesMake(make) {
+		return invalid("Error: The VIN is for a " + d.Manufacturer + ", not a " + make)
+	}
+	if d.ModelYear != 0 && year != 0 && d.ModelYear != year {
+		return invalid("Error: The VIN is for a " + strconv.Itoa(d.ModelYear) + " model year, not " + strconv.Itoa(year))
+	}
+	return nil
+}
+
+// MatchesMake compares a make to the VIN's manufacturer ignoring case, spaces and dashes, so "Land Rover"
+// matches "Landrover". Unknown manufacturers, including WMIs shared by several brands, match any make.
+func (d DecodedVIN) MatchesMake(make string) bool {
+	if d.Manufacturer == "" {
+		return true
+	}
+	simplify := func(value string) string {
+		value = strings.ToLower(value)
+		value = strings.ReplaceAll(value, " ", "")
+		return strings.ReplaceAll(value, "-", "")
+	}
+	return simplify(d.Manufacturer) == simplify(make)
+}
@@ -0,0 +1,98 @@
+package cars
+
+// wmiManufacturers maps world manufacturer identifiers to the make they build. It is bundled so VINs can be
+// decoded without calling out to a lookup service. Only common passenger car WMIs are listed; a VIN with a
+// WMI that is not here is still valid, its make just can not be checked. WMIs that several brands share, like
+// 1C3 and 1C4 for Chrysler, Dodge and Jeep, are left out on purpose so a correct make is never rejected.
+var wmiManufacturers = map[string]string{
+	// North America
+	"1C6": "Ram",
+	"1FA": "Ford",
+	"1FM": "Ford",
+	"1FT": "Ford",
+	"1G1": "Chevrolet",
+	"1GC": "Chevrolet",
+	"1GN": "Chevrolet",
+	"1G4": "Buick",
+	"1G6": "Cadillac",
+	"1GT": "GMC",
+	"1HG": "Honda",
+	"1J4": "Jeep",
+	"1LN": "Lincoln",
+	"1N4": "Nissan",
+	"1N6": "Nissan",
+	"1VW": "Volkswagen",
+	"2FA": "Ford",
+	"2G1": "Chevrolet",
+	"2HG": "Honda",
+	"2HK": "Honda",
+	"2T1": "Toyota",
+	"2T3": "Toyota",
+	"3FA": "Ford",
+	"3G1": "Chevrolet",
+	"3HG": "Honda",
+	"3N1": "Nissan",
+	"3VW": "Volkswagen",
+	"4S3": "Subaru",
+	"4S4": "Subaru",
+	"4T1": "Toyota",
+	"4T3": "Toyota",
+	"5FN": "Honda",
+	"5J6": "Honda",
+	"5N1": "Nissan",
+	"5NP": "Hyundai",
+	"5TD": "Toyota",
+	"5UX": "BMW",
+	"5YJ": "Tesla",
+	"7SA": "Tesla",
+
+	// Asia
+	"JA3": "Mitsubishi",
+	"JF1": "Subaru",
+	"JF2": "Subaru",
+	"JHM": "Honda",
+	"JM1": "Mazda",
+	"JM3": "Mazda",
+	"JN1": "Nissan",
+	"JN8": "Nissan",
+	"JT2": "Toyota",
+	"JTD": "Toyota",
+	"JTH": "Lexus",
+	"JTM": "Toyota",
+	"KMH": "Hyundai",
+	"KNA": "Kia",
+	"KND": "Kia",
+	"LRW": "Tesla",
+	"LVS": "Ford",
+
+	// Europe
+	"SAJ": "Jaguar",
+	"SAL": "Land Rover",
+	"SCC": "Lotus",
+	"SCF": "Aston Martin",
+	"SHH": "Honda",
+	"TMB": "Skoda",
+	"TRU": "Audi",
+	"VF1": "Renault",
+	"VF3": "Peugeot",
+	"VF7": "Citroen",
+	"VSS": "SEAT",
+	"WAU": "Audi",
+	"WA1": "Audi",
+	"WBA": "BMW",
+	"WBS": "BMW",
+	"WDB": "Mercedes-Benz",
+	"WDD": "Mercedes-Benz",
+	"WMW": "MINI",
+	"WP0": "Porsche",
+	"WP1": "Porsche",
+	"WVW": "Volkswagen",
+	"WVG": "Volkswagen",
+	"WF0": "Ford",
+	"YS3": "Saab",
+	"YV1": "Volvo",
+	"ZAR": "Alfa Romeo",
+	"ZFA": "Fiat",
+	"ZFF": "Ferrari",
+	"ZHW": "Lamborghini",
+}
@@ -0,0 +1,119 @@
+package cars
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// An XLSX file is a zip of XML parts. Only the parts Excel needs to open a single sheet are written: the content
+// types, the package and workbook relationships, the workbook, and the sheet. Strings are written inline in
+// each cell rather than in a shared strings part, so rows can be streamed straight into the sheet.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Cars" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+type xlsxExporter struct {
+	zip     *zip.Writer
+	sheet   io.Writer
+	columns []string
+	row     int
+}
+
+func newXLSXExporter(w io.Writer, columns []string) *xlsxExporter {
+	return &xlsxExporter{zip: zip.NewWriter(w), columns: columns}
+}
+
+// Begin writes the fixed parts and opens the sheet with the header row. The sheet has to be the last part since
+// a zip is written one file at a time.
+func (e *xlsxExporter) Begin() error {
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRelationships},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	}
+	for _, part := range parts {
+		w, err := e.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	if _, err := io.WriteString(e.sheet, xlsxSheetStart); err != nil {
+		return err
+	}
+
+	header := []interface{}{}
+	for _, column := range e.columns {
+		header = append(header, column)
+	}
+	return e.Write(header)
+}
+
+func (e *xlsxExporter) Write(values []interface{}) error {
+	e.row++
+	rowNumber := strconv.Itoa(e.row)
+	if _, err := io.WriteString(e.sheet, `<row r="`+rowNumber+`">`); err != nil {
+		return err
+	}
+	for i, value := range values {
+		ref := xlsxColumnName(i) + rowNumber
+		if number, ok := value.(int); ok {
+			if _, err := io.WriteString(e.sheet, `<c r="`+ref+`"><v>`+strconv.Itoa(number)+`</v></c>`); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.WriteString(e.sheet, `<c r="`+ref+`" t="inlineStr"><is><t xml:space="preserve">`); err != nil {
+			return err
+		}
+		if err := xml.EscapeText(e.sheet, []byte(cellText(value))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(e.sheet, `</t></is></c>`); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(e.sheet, `</row>`)
+	return err
+}
+
+func (e *xlsxExporter) Close() error {
+	if _, err := io.WriteString(e.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+// xlsxColumnName turns a zero based column index into its letters, so 0 is A, 25 is Z and 26 is AA.
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,9 @@
+package cartransferemail
+
+import "go-boilerplate/integrations/sendgrid"
+
+func SendCarTransferEmail(fullName string, email string, senderName string, carDescription string, code string) error {
+	plainTextContent := "Hi " + fullName + ",\n\n" + senderName + " would like to transfer their " + carDescription + " to you. Accepting moves the car and its history into your account.\n\nAccept: https://yourwebsite.com/cars/transfers/accept?code=" + code + "\nDecline: https://yourwebsite.com/cars/transfers/decline?code=" + code + "\n\nThe transfer expires in 7 days."
+	htmlContent := "<html> <body> <div style='width: 98%; margin-left:auto; margin-right:auto; padding-top: 15px; padding-bottom: 20px; background-color: #f1f1f1;'> <div style='background-color: #fff; width: 90%; margin-left:auto; margin-right:auto;padding-top: 15px;'> <div style='width: 100%;padding: 10px 20px;'> <img src='https://upload.wikimedia.org/wikipedia/commons/thumb/0/08/Circle-icons-rocket.svg/1200px-Circle-icons-rocket.svg.png' style='height: 50px;' /> <h1 style='font-size: 32px;font-family: sans-serif;margin-top: 0px; margin-bottom: 0px;padding-top: 10px; padding-bottom: 10px;'>A Car Is Being Transferred to You</h1> </div> <div style='padding: 10px 25px;'> <p style='margin-top: 0px; margin-bottom: 0px; font-size: 16px; font-family: sans-serif;'> Hi " + fullName + ",<br /> <br /> " + senderName + " would like to transfer their " + carDescription + " to you. Accepting moves the car and its history into your account. </p> <div style='width: 100%; padding: 20px 5px; text-align: center;'> <a href='https://yourwebsite.com/cars/transfers/accept?code=" + code + "' style='padding: 10px 50px; background-color: #0489B1; color: #fff; border-width: 0px;font-size: 20px; text-decoration: none; font-family: sans-serif;'>Accept Transfer</a> </div> <p style='font-size: 16px; font-family: sans-serif; padding-top: 15px;'> Not yours? <a href='https://yourwebsite.com/cars/transfers/decline?code=" + code + "' style='text-decoration: underline; color: #2e2e2e;'>Decline the transfer</a>. The transfer expires in 7 days. </p> </div> <div style='padding-top: 15px; padding-bottom: 25px; text-align: center;'> <p style='font-size: 14px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px;'>Made by KeithWeaver</p> <p style='font-size: 12px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px; padding: 10px 0px;'> <a href='https://yourwebsite.com/blog' style='text-decoration: underline; color: #2e2e2e;'> Our Blog </a> <a href='https://yourwebsite.com/privacy' style='text-decoration: underline; color: #2e2e2e; padding: 0px 15px;'> Our Privacy Policy </a> <p> </div> </div> </div> </body> </html>"
+	return sendgrid.SendEmail(fullName, email, "A Car Is Being Transferred to You", plainTextContent, htmlContent)
+}
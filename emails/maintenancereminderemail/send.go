@@ -0,0 +1,15 @@
+package maintenancereminderemail
+
+import (
+	"go-boilerplate/integrations/sendgrid"
+	"html"
+)
+
+func SendMaintenanceReminderEmail(fullName string, email string, title string, carDescription string, due string, carID string) error {
+	plainTextContent := "Hi " + fullName + ",\n\n" + title + " on your " + carDescription + " is due " + due + ".\n\nView the reminder: https://yourwebsite.com/cars/" + carID + "/reminders\n\nMark it complete once it's done, or snooze it to be reminded later."
+	// The title and car are typed in by users, so they are escaped for the HTML version
+	htmlTitle := html.EscapeString(title)
+	htmlCarDescription := html.EscapeString(carDescription)
+	htmlContent := "<html> <body> <div style='width: 98%; margin-left:auto; margin-right:auto; padding-top: 15px; padding-bottom: 20px; background-color: #f1f1f1;'> <div style='background-color: #fff; width: 90%; margin-left:auto; margin-right:auto;padding-top: 15px;'> <div style='width: 100%;padding: 10px 20px;'> <img src='https://upload.wikimedia.org/wikipedia/commons/thumb/0/08/Circle-icons-rocket.svg/1200px-Circle-icons-rocket.svg.png' style='height: 50px;' /> <h1 style='font-size: 32px;font-family: sans-serif;margin-top: 0px; margin-bottom: 0px;padding-top: 10px; padding-bottom: 10px;'>Maintenance Is Due</h1> </div> <div style='padding: 10px 25px;'> <p style='margin-top: 0px; margin-bottom: 0px; font-size: 16px; font-family: sans-serif;'> Hi " + fullName + ",<br /> <br /> " + htmlTitle + " on your " + htmlCarDescription + " is due " + due + ". </p> <div style='width: 100%; padding: 20px 5px; text-align: center;'> <a href='https://yourwebsite.com/cars/" + carID + "/reminders' style='padding: 10px 50px; background-color: #0489B1; color: #fff; border-width: 0px;font-size: 20px; text-decoration: none; font-family: sans-serif;'>View Reminder</a> </div> <p style='font-size: 16px; font-family: sans-serif; padding-top: 15px;'> Mark it complete once it's done, or snooze it to be reminded later. </p> </div> <div style='padding-top: 15px; padding-bottom: 25px; text-align: center;'> <p style='font-size: 14px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px;'>Made by KeithWeaver</p> <p style='font-size: 12px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px; padding: 10px 0px;'> <a href='https://yourwebsite.com/blog' style='text-decoration: underline; color: #2e2e2e;'> Our Blog </a> <a href='https://yourwebsite.com/privacy' style='text-decoration: underline; color: #2e2e2e; padding: 0px 15px;'> Our Privacy Policy </a> <p> </div> </div> </div> </body> </html>"
+	return sendgrid.SendEmail(fullName, email, "Maintenance Due: "+title, plainTextContent, htmlContent)
+}
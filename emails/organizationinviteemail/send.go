@@ -0,0 +1,9 @@
+package organizationinviteemail
+
+import "go-boilerplate/integrations/sendgrid"
+
+func SendOrganizationInviteEmail(fullName string, email string, inviterName string, organizationName string, code string) error {
+	plainTextContent := "Hi " + fullName + ",\n\n" + inviterName + " has invited you to join " + organizationName + ". Please use the follow link to accept: https://yourwebsite.com/organizations/accept-invitation?code=" + code + "\n\nThe invitation expires in 7 days. If you were not expecting this, you can ignore this email."
+	htmlContent := "<html> <body> <div style='width: 98%; margin-left:auto; margin-right:auto; padding-top: 15px; padding-bottom: 20px; background-color: #f1f1f1;'> <div style='background-color: #fff; width: 90%; margin-left:auto; margin-right:auto;padding-top: 15px;'> <div style='width: 100%;padding: 10px 20px;'> <img src='https://upload.wikimedia.org/wikipedia/commons/thumb/0/08/Circle-icons-rocket.svg/1200px-Circle-icons-rocket.svg.png' style='height: 50px;' /> <h1 style='font-size: 32px;font-family: sans-serif;margin-top: 0px; margin-bottom: 0px;padding-top: 10px; padding-bottom: 10px;'>You Have Been Invited</h1> </div> <div style='padding: 10px 25px;'> <p style='margin-top: 0px; margin-bottom: 0px; font-size: 16px; font-family: sans-serif;'> Hi " + fullName + ",<br /> <br /> " + inviterName + " has invited you to join " + organizationName + ". Please use the button below to accept: </p> <div style='width: 100%; padding: 20px 5px; text-align: center;'> <a href='https://yourwebsite.com/organizations/accept-invitation?code=" + code + "' style='padding: 10px 50px; background-color: #0489B1; color: #fff; border-width: 0px;font-size: 20px; text-decoration: none; font-family: sans-serif;'>Accept Invitation</a> </div> <p style='font-size: 16px; font-family: sans-serif; padding-top: 15px;'> The invitation expires in 7 days. If you were not expecting this, you can ignore this email. </p> </div> <div style='padding-top: 15px; padding-bottom: 25px; text-align: center;'> <p style='font-size: 14px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px;'>Made by KeithWeaver</p> <p style='font-size: 12px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px; padding: 10px 0px;'> <a href='https://yourwebsite.com/blog' style='text-decoration: underline; color: #2e2e2e;'> Our Blog </a> <a href='https://yourwebsite.com/privacy' style='text-decoration: underline; color: #2e2e2e; padding: 0px 15px;'> Our Privacy Policy </a> <p> </div> </div> </div> </body> </html>"
+	return sendgrid.SendEmail(fullName, email, "Invitation to Join " + organizationName, plainTextContent, htmlContent)
+}
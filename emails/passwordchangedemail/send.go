@@ -0,0 +1,9 @@
+package passwordchangedemail
+
+import "go-boilerplate/integrations/sendgrid"
+
+func SendPasswordChangedEmail(fullName string, email string) error {
+	plainTextContent := "Hi " + fullName + ",\n\nThe password for your account was just changed. For your security, all devices have been signed out.\n\nIf this was you, you’re all set!\n\nIf this was not you, please reset your password using forgot password (https://yourwebsite.com/forgot-password) and reach out to our support team (support@yourwebsite.com)."
+	htmlContent := "<html> <body> <div style='width: 98%; margin-left:auto; margin-right:auto; padding-top: 15px; padding-bottom: 20px; background-color: #f1f1f1;'> <div style='background-color: #fff; width: 90%; margin-left:auto; margin-right:auto;padding-top: 15px;'> <div style='width: 100%;padding: 10px 20px;'> <img src='https://upload.wikimedia.org/wikipedia/commons/thumb/0/08/Circle-icons-rocket.svg/1200px-Circle-icons-rocket.svg.png' style='height: 50px;' /> <h1 style='font-size: 32px;font-family: sans-serif;margin-top: 0px; margin-bottom: 0px;padding-top: 10px; padding-bottom: 10px;'>Your Password Was Changed</h1> </div> <div style='padding: 10px 25px;'> <p style='margin-top: 0px; margin-bottom: 0px; font-size: 16px; font-family: sans-serif;'> Hi " + fullName + ",<br /> <br /> The password for your account was just changed. For your security, all devices have been signed out. </p> <p style='font-size: 16px; font-family: sans-serif; padding-top: 15px;'> If this was you, you’re all set!<br /> <br /> If this was not you, please reset your password using <a href='https://yourwebsite.com/forgot-password' style='text-decoration: underline; color: #2e2e2e;'>forgot password</a> and reach out to <a href='https://yourwebsite.com/support' style='text-decoration: underline; color: #2e2e2e;'>our support team</a>. </p> </div> <div style='padding-top: 15px; padding-bottom: 25px; text-align: center;'> <p style='font-size: 14px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px;'>Made by KeithWeaver</p> <p style='font-size: 12px; font-family: sans-serif; margin-top: 0px; margin-bottom: 0px; padding: 10px 0px;'> <a href='https://yourwebsite.com/blog' style='text-decoration: underline; color: #2e2e2e;'> Our Blog </a> <a href='https://yourwebsite.com/privacy' style='text-decoration: underline; color: #2e2e2e; padding: 0px 15px;'> Our Privacy Policy </a> <p> </div> </div> </div> </body> </html>"
+	return sendgrid.SendEmail(fullName, email, "Your Password Was Changed", plainTextContent, htmlContent)
+}
@@ -11,6 +11,12 @@ import (
 // - SENDER_NAME
 // - DB_NAME
 // - FRONTEND_DOMAIN
+// - PASSWORD_HISTORY_LIMIT (Optional, defaults to 5)
+// - PASSWORD_MAX_AGE_DAYS (Optional, passwords never expire when not set)
+// - ADMIN_EMAILS (Optional, comma separated list of emails that can use /admin)
+// - STORAGE_PATH (Optional, directory uploaded files are kept in, defaults to uploads)
+// - CAR_TRASH_RETENTION_DAYS (Optional, days deleted cars can be restored for, defaults to 30)
+// - IP_LOCATION_URL (Optional, IP location lookup for sign in emails with {ip} for the address, Ex. http://ip-api.com/json/{ip})
 
 
 // VerifyRequiredEnvVarsSet checks that the minimum set of environment variables
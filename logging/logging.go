@@ -19,6 +19,7 @@ var CtxServiceMethod = "serviceMethod"
 var CtxHelpMethods = "helperMethods"
 var CtxEmail = "email"
 var CtxClientIP = "clientIP"
+var CtxUserAgent = "userAgent"
 var loggingAttributes = []string{
 	CtxRequestID,
 	CtxDomain,
@@ -26,6 +27,7 @@ var loggingAttributes = []string{
 	CtxServiceMethod,
 	CtxEmail,
 	CtxClientIP,
+	CtxUserAgent,
 	CtxHelpMethods,
 }
 
@@ -3,17 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"go-boilerplate/audit"
 	"go-boilerplate/auth"
 	"go-boilerplate/cars"
 	"go-boilerplate/env"
 	"go-boilerplate/health"
 	"go-boilerplate/logging"
 	"go-boilerplate/middleware"
+	"go-boilerplate/organizations"
+	"go-boilerplate/storage"
 	"go-boilerplate/user"
 	"os"
 
 	//"strings"
-	// "time"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go-boilerplate/db"
@@ -41,15 +44,70 @@ func main() {
 	// Repositories
 	userRepository := user.NewInstanceOfUserRepository(db)
 	carsRepository := cars.NewInstanceOfCarsRepository(db)
+	transferRepository := cars.NewInstanceOfTransferRepository(db)
+	importRepository := cars.NewInstanceOfImportRepository(db)
+	serviceRecordRepository := cars.NewInstanceOfServiceRecordRepository(db)
+	fuelRepository := cars.NewInstanceOfFuelRepository(db)
+	mileageRepository := cars.NewInstanceOfMileageRepository(db)
+	reminderRepository := cars.NewInstanceOfReminderRepository(db)
+	documentRepository := cars.NewInstanceOfDocumentRepository(db)
+	photoRepository := cars.NewInstanceOfPhotoRepository(db)
+	revisionRepository := cars.NewInstanceOfRevisionRepository(db)
 	forgotPasswordRepository := user.NewInstanceOfForgotPasswordRepository(db)
+	auditRepository := audit.NewInstanceOfAuditRepository(db)
+	organizationsRepository := organizations.NewInstanceOfOrganizationsRepository(db)
+
+	if err := importRepository.FailRunning(); err != nil {
+		fmt.Println("Failed to mark interrupted imports as failed")
+		panic(err)
+	}
+	if err := carsRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create car indexes")
+		panic(err)
+	}
+	if err := serviceRecordRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create service record indexes")
+		panic(err)
+	}
+	if err := fuelRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create fuel log indexes")
+		panic(err)
+	}
+	if err := reminderRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create reminder indexes")
+		panic(err)
+	}
+	if err := documentRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create document indexes")
+		panic(err)
+	}
+	if err := photoRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create photo indexes")
+		panic(err)
+	}
+	if err := revisionRepository.CreateIndexes(); err != nil {
+		fmt.Println("Failed to create revision indexes")
+		panic(err)
+	}
+
+	// Storage
+	fileStorage := storage.NewInstanceOfLocalStorage(storage.GetLocalStoragePath())
 
 	// Services
-	userServices := user.NewInstanceOfUserServices(logger, userRepository, forgotPasswordRepository)
-	carsServices := cars.NewInstanceOfCarsServices(logger, userRepository, carsRepository)
+	auditServices := audit.NewInstanceOfAuditServices(logger, auditRepository)
+	userServices := user.NewInstanceOfUserServices(logger, userRepository, forgotPasswordRepository, auditServices)
+	carsServices := cars.NewInstanceOfCarsServices(logger, userRepository, carsRepository, transferRepository, importRepository, serviceRecordRepository, fuelRepository, mileageRepository, reminderRepository, documentRepository, photoRepository, revisionRepository, fileStorage, organizationsRepository, auditServices)
+	organizationsServices := organizations.NewInstanceOfOrganizationsServices(logger, userRepository, organizationsRepository, auditServices)
+
+	// Background jobs
+	carsServices.StartReminderScheduler(context.Background(), time.Hour)
+	carsServices.StartTrashPurge(context.Background(), 24*time.Hour)
 
 	// Handlers
 	userHandlers := user.NewInstanceOfUserHandlers(logger, userServices)
 	carsHandlers := cars.NewInstanceOfCarsHandlers(logger, carsServices)
+	auditHandlers := audit.NewInstanceOfAuditHandlers(logger, auditServices)
+	organizationsHandlers := organizations.NewInstanceOfOrganizationsHandlers(logger, organizationsServices)
 
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware())
@@ -68,15 +126,75 @@ func main() {
 		userAPI.POST("/session/unlock", userHandlers.UnlockSession)
 		userAPI.POST("/forgot-password/", userHandlers.SendForgotPassword)
 		userAPI.POST("/forgot-password/reset", userHandlers.ForgotPassword)
+		userAPI.POST("/password", auth.ValidateAuthAllowPasswordReset(userRepository), userHandlers.ChangePassword)
+		userAPI.GET("/notifications", auth.ValidateAuth(userRepository), userHandlers.GetNotificationPreferences)
+		userAPI.PUT("/notifications", auth.ValidateAuth(userRepository), userHandlers.UpdateNotificationPreferences)
+		userAPI.GET("/activity", auth.ValidateAuth(userRepository), userHandlers.GetActivity)
 	}
 
 	carsAPI := router.Group("/cars")
 	{
 		carsAPI.GET("/", auth.ValidateAuth(userRepository), carsHandlers.GetAll)
+		carsAPI.GET("/trash", auth.ValidateAuth(userRepository), carsHandlers.GetTrash)
+		carsAPI.GET("/stats", auth.ValidateAuth(userRepository), carsHandlers.GetStats)
+		carsAPI.GET("/export", auth.ValidateAuth(userRepository), carsHandlers.Export)
+		carsAPI.POST("/import", auth.ValidateAuth(userRepository), carsHandlers.Import)
+		carsAPI.GET("/import/:jobId", auth.ValidateAuth(userRepository), carsHandlers.GetImport)
+		carsAPI.GET("/transfers", auth.ValidateAuth(userRepository), carsHandlers.GetTransfers)
+		carsAPI.POST("/transfers/accept", auth.ValidateAuth(userRepository), carsHandlers.AcceptTransfer)
+		carsAPI.POST("/transfers/decline", auth.ValidateAuth(userRepository), carsHandlers.DeclineTransfer)
 		carsAPI.GET("/:id", auth.ValidateAuth(userRepository), carsHandlers.GetByID)
 		carsAPI.POST("/", auth.ValidateAuth(userRepository), carsHandlers.Create)
 		carsAPI.PUT("/:id", auth.ValidateAuth(userRepository), carsHandlers.Update)
+		carsAPI.PATCH("/:id", auth.ValidateAuth(userRepository), carsHandlers.Patch)
 		carsAPI.DELETE("/:id", auth.ValidateAuth(userRepository), carsHandlers.Delete)
+		carsAPI.POST("/:id/restore", auth.ValidateAuth(userRepository), carsHandlers.Restore)
+		carsAPI.GET("/:id/status-history", auth.ValidateAuth(userRepository), carsHandlers.GetStatusHistory)
+		carsAPI.GET("/:id/revisions", auth.ValidateAuth(userRepository), carsHandlers.GetRevisions)
+		carsAPI.POST("/:id/revisions/:number/revert", auth.ValidateAuth(userRepository), carsHandlers.RevertToRevision)
+		carsAPI.GET("/:id/services", auth.ValidateAuth(userRepository), carsHandlers.GetServiceRecords)
+		carsAPI.POST("/:id/services", auth.ValidateAuth(userRepository), carsHandlers.CreateServiceRecord)
+		carsAPI.GET("/:id/services/:recordId", auth.ValidateAuth(userRepository), carsHandlers.GetServiceRecord)
+		carsAPI.PUT("/:id/services/:recordId", auth.ValidateAuth(userRepository), carsHandlers.UpdateServiceRecord)
+		carsAPI.DELETE("/:id/services/:recordId", auth.ValidateAuth(userRepository), carsHandlers.DeleteServiceRecord)
+		carsAPI.GET("/:id/fuel", auth.ValidateAuth(userRepository), carsHandlers.GetFuelLogs)
+		carsAPI.POST("/:id/fuel", auth.ValidateAuth(userRepository), carsHandlers.CreateFuelLog)
+		carsAPI.DELETE("/:id/fuel/:logId", auth.ValidateAuth(userRepository), carsHandlers.DeleteFuelLog)
+		carsAPI.GET("/:id/mileage", auth.ValidateAuth(userRepository), carsHandlers.GetMileage)
+		carsAPI.POST("/:id/mileage", auth.ValidateAuth(userRepository), carsHandlers.CreateOdometerReading)
+		carsAPI.DELETE("/:id/mileage/:readingId", auth.ValidateAuth(userRepository), carsHandlers.DeleteOdometerReading)
+		carsAPI.GET("/:id/reminders", auth.ValidateAuth(userRepository), carsHandlers.GetReminders)
+		carsAPI.POST("/:id/reminders", auth.ValidateAuth(userRepository), carsHandlers.CreateReminder)
+		carsAPI.POST("/:id/reminders/:reminderId/snooze", auth.ValidateAuth(userRepository), carsHandlers.SnoozeReminder)
+		carsAPI.POST("/:id/reminders/:reminderId/complete", auth.ValidateAuth(userRepository), carsHandlers.CompleteReminder)
+		carsAPI.DELETE("/:id/reminders/:reminderId", auth.ValidateAuth(userRepository), carsHandlers.DeleteReminder)
+		carsAPI.GET("/:id/documents", auth.ValidateAuth(userRepository), carsHandlers.GetDocuments)
+		carsAPI.POST("/:id/documents", auth.ValidateAuth(userRepository), carsHandlers.UploadDocument)
+		carsAPI.GET("/:id/documents/:documentId/download", auth.ValidateAuth(userRepository), carsHandlers.DownloadDocument)
+		carsAPI.DELETE("/:id/documents/:documentId", auth.ValidateAuth(userRepository), carsHandlers.DeleteDocument)
+		carsAPI.GET("/:id/photos", auth.ValidateAuth(userRepository), carsHandlers.GetPhotos)
+		carsAPI.POST("/:id/photos", auth.ValidateAuth(userRepository), carsHandlers.UploadPhoto)
+		carsAPI.GET("/:id/photos/:photoId/:variant", auth.ValidateAuth(userRepository), carsHandlers.DownloadPhoto)
+		carsAPI.POST("/:id/photos/:photoId/primary", auth.ValidateAuth(userRepository), carsHandlers.SetPrimaryPhoto)
+		carsAPI.DELETE("/:id/photos/:photoId", auth.ValidateAuth(userRepository), carsHandlers.DeletePhoto)
+		carsAPI.POST("/:id/transfers", auth.ValidateAuth(userRepository), carsHandlers.InitiateTransfer)
+		carsAPI.DELETE("/:id/transfers", auth.ValidateAuth(userRepository), carsHandlers.CancelTransfer)
+	}
+
+	organizationsAPI := router.Group("/organizations")
+	{
+		organizationsAPI.GET("/", auth.ValidateAuth(userRepository), organizationsHandlers.GetAll)
+		organizationsAPI.POST("/", auth.ValidateAuth(userRepository), organizationsHandlers.Create)
+		organizationsAPI.POST("/invitations/accept", auth.ValidateAuth(userRepository), organizationsHandlers.AcceptInvitation)
+		organizationsAPI.GET("/:id", auth.ValidateAuth(userRepository), organizationsHandlers.GetByID)
+		organizationsAPI.POST("/:id/invitations", auth.ValidateAuth(userRepository), organizationsHandlers.Invite)
+		organizationsAPI.PUT("/:id/members/:email", auth.ValidateAuth(userRepository), organizationsHandlers.UpdateMember)
+		organizationsAPI.DELETE("/:id/members/:email", auth.ValidateAuth(userRepository), organizationsHandlers.RemoveMember)
+	}
+
+	adminAPI := router.Group("/admin")
+	{
+		adminAPI.GET("/audit", auth.ValidateAuth(userRepository), auth.ValidateAdmin(), auditHandlers.List)
 	}
 
 	router.Run(":8080")
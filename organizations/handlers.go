@@ -0,0 +1,239 @@
+package organizations
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go-boilerplate/common"
+	"go-boilerplate/logging"
+	"go-boilerplate/user"
+	"strings"
+)
+
+type Handlers struct {
+	logger                logging.Logger
+	organizationsServices Services
+}
+
+func NewInstanceOfOrganizationsHandlers(logger logging.Logger, organizationsServices Services) *Handlers {
+	return &Handlers{logger, organizationsServices}
+}
+
+func (h *Handlers) GetSession(c *gin.Context) (user.Session, bool) {
+	i, exists := c.Get("session")
+	if !exists {
+		return user.Session{}, false
+	}
+	session, ok := i.(user.Session)
+	if !ok {
+		return user.Session{}, false
+	}
+	return session, true
+}
+
+func (h *Handlers) Create(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Create")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	var body CreateOrganizationBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.logger.Warning(ctx, "invalid request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400})
+		return
+	}
+	if err := body.Validate(); err != nil {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	organization, err := h.organizationsServices.Create(ctx, session, body)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Created organization", "organization": organization})
+	return
+}
+
+func (h *Handlers) GetAll(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetAll")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	organizations, err := h.organizationsServices.GetAll(ctx, session)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Organizations retrieved", "organizations": organizations})
+	return
+}
+
+func (h *Handlers) GetByID(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetByID")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	organization, members, err := h.organizationsServices.GetByID(ctx, session, c.Param("id"))
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Organization retrieved", "organization": organization, "members": members})
+	return
+}
+
+func (h *Handlers) Invite(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "Invite")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	var body InviteMemberBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.logger.Warning(ctx, "invalid request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400})
+		return
+	}
+	if err := body.Validate(); err != nil {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	err := h.organizationsServices.Invite(ctx, session, c.Param("id"), body)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Invitation sent"})
+	return
+}
+
+func (h *Handlers) AcceptInvitation(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "AcceptInvitation")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	var body AcceptInvitationBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.logger.Warning(ctx, "invalid request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400})
+		return
+	}
+	if err := body.Validate(); err != nil {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	membership, err := h.organizationsServices.AcceptInvitation(ctx, session, body)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Invitation accepted", "membership": membership})
+	return
+}
+
+func (h *Handlers) UpdateMember(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "UpdateMember")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	var body UpdateMemberBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.logger.Warning(ctx, "invalid request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400})
+		return
+	}
+	if err := body.Validate(); err != nil {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	email := strings.ToLower(c.Param("email"))
+	err := h.organizationsServices.UpdateMember(ctx, session, c.Param("id"), email, body)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Member updated"})
+	return
+}
+
+func (h *Handlers) RemoveMember(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "Organizations")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "RemoveMember")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := h.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	email := strings.ToLower(c.Param("email"))
+	err := h.organizationsServices.RemoveMember(ctx, session, c.Param("id"), email)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Member removed"})
+	return
+}
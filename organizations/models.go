@@ -0,0 +1,132 @@
+package organizations
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// Roles from most to least access
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// Permissions are checked instead of roles so the role definitions only live in one spot.
+const (
+	PermissionViewCars      = "cars:view"
+	PermissionEditCars      = "cars:edit"
+	PermissionDeleteCars    = "cars:delete"
+	PermissionManageMembers = "members:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleOwner:  {PermissionViewCars, PermissionEditCars, PermissionDeleteCars, PermissionManageMembers},
+	RoleAdmin:  {PermissionViewCars, PermissionEditCars, PermissionDeleteCars, PermissionManageMembers},
+	RoleMember: {PermissionViewCars, PermissionEditCars},
+	RoleViewer: {PermissionViewCars},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func RoleHasPermission(role string, permission string) bool {
+	for _, rolePermission := range rolePermissions[role] {
+		if rolePermission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type Organization struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	CreatedBy string             `json:"createdBy" bson:"createdBy"`
+	Created   time.Time          `json:"created" bson:"created"`
+}
+
+type Membership struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationID string             `json:"organizationId" bson:"organizationId"`
+	Email          string             `json:"email" bson:"email"`
+	Role           string             `json:"role" bson:"role"`
+	Created        time.Time          `json:"created" bson:"created"`
+}
+
+// OrganizationWithRole is an organization the signed in user belongs to, along with their role in it.
+type OrganizationWithRole struct {
+	Organization
+	Role string `json:"role"`
+}
+
+type Invitation struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationID string             `json:"organizationId" bson:"organizationId"`
+	Email          string             `json:"email" bson:"email"`
+	Role           string             `json:"role" bson:"role"`
+	Code           string             `json:"-" bson:"code"`
+	InvitedBy      string             `json:"invitedBy" bson:"invitedBy"`
+	Accepted       bool               `json:"accepted" bson:"accepted"`
+	Created        time.Time          `json:"created" bson:"created"`
+	Expiry         time.Time          `json:"expiry" bson:"expiry"`
+}
+
+type CreateOrganizationBody struct {
+	Name string `json:"name"`
+}
+
+func (b *CreateOrganizationBody) Validate() error {
+	if strings.Trim(b.Name, " ") == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type InviteMemberBody struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (b *InviteMemberBody) Validate() error {
+	if b.Email == "" {
+		return errors.New("email is required")
+	}
+	if !IsValidRole(b.Role) {
+		return errors.New("role must be one of: owner, admin, member, viewer")
+	}
+	return nil
+}
+
+func (b *InviteMemberBody) GetFormattedEmail() string {
+	email := strings.Trim(b.Email, " ")
+	email = strings.ToLower(email)
+	return email
+}
+
+type AcceptInvitationBody struct {
+	Code string `json:"code"`
+}
+
+func (b *AcceptInvitationBody) Validate() error {
+	if b.Code == "" {
+		return errors.New("code is required")
+	}
+	return nil
+}
+
+type UpdateMemberBody struct {
+	Role string `json:"role"`
+}
+
+func (b *UpdateMemberBody) Validate() error {
+	if !IsValidRole(b.Role) {
+		return errors.New("role must be one of: owner, admin, member, viewer")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package organizations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Repository struct {
+	db                      *mongo.Database
+	organizationsCollection string
+	membersCollection       string
+	invitationsCollection   string
+}
+
+func NewInstanceOfOrganizationsRepository(db *mongo.Database) Repository {
+	return Repository{
+		db:                      db,
+		organizationsCollection: "organizations",
+		membersCollection:       "organizationMembers",
+		invitationsCollection:   "organizationInvitations",
+	}
+}
+
+func (r *Repository) SaveOrganization(organization Organization) (string, error) {
+	insertResult, err := r.db.Collection(r.organizationsCollection).InsertOne(context.TODO(), organization)
+	if err != nil {
+		return "", err
+	}
+	return insertResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (r *Repository) GetOrganization(organizationID string) (bool, Organization, error) {
+	docID, err := primitive.ObjectIDFromHex(organizationID)
+	if err != nil {
+		return false, Organization{}, nil
+	}
+
+	var organization Organization
+	err = r.db.Collection(r.organizationsCollection).FindOne(context.TODO(), bson.M{"_id": docID}).Decode(&organization)
+	if err == mongo.ErrNoDocuments {
+		return false, Organization{}, nil
+	}
+	if err != nil {
+		return false, Organization{}, err
+	}
+	return true, organization, nil
+}
+
+func (r *Repository) ListOrganizations(organizationIDs []string) ([]Organization, error) {
+	docIDs := []primitive.ObjectID{}
+	for _, organizationID := range organizationIDs {
+		docID, err := primitive.ObjectIDFromHex(organizationID)
+		if err != nil {
+			return []Organization{}, err
+		}
+		docIDs = append(docIDs, docID)
+	}
+
+	cursor, err := r.db.Collection(r.organizationsCollection).Find(context.TODO(), bson.M{"_id": bson.M{"$in": docIDs}})
+	if err != nil {
+		return []Organization{}, err
+	}
+	organizations := []Organization{}
+	if err := cursor.All(context.TODO(), &organizations); err != nil {
+		return []Organization{}, err
+	}
+	return organizations, nil
+}
+
+func (r *Repository) SaveMembership(membership Membership) error {
+	_, err := r.db.Collection(r.membersCollection).InsertOne(context.TODO(), membership)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetMembership(organizationID string, email string) (bool, Membership, error) {
+	var membership Membership
+	filter := bson.M{"organizationId": organizationID, "email": email}
+	err := r.db.Collection(r.membersCollection).FindOne(context.TODO(), filter).Decode(&membership)
+	if err == mongo.ErrNoDocuments {
+		return false, Membership{}, nil
+	}
+	if err != nil {
+		return false, Membership{}, err
+	}
+	return true, membership, nil
+}
+
+func (r *Repository) ListMembershipsByEmail(email string) ([]Membership, error) {
+	return r.listMemberships(bson.M{"email": email})
+}
+
+func (r *Repository) ListMembershipsByOrganization(organizationID string) ([]Membership, error) {
+	return r.listMemberships(bson.M{"organizationId": organizationID})
+}
+
+func (r *Repository) listMemberships(filter bson.M) ([]Membership, error) {
+	cursor, err := r.db.Collection(r.membersCollection).Find(context.TODO(), filter)
+	if err != nil {
+		return []Membership{}, err
+	}
+	memberships := []Membership{}
+	if err := cursor.All(context.TODO(), &memberships); err != nil {
+		return []Membership{}, err
+	}
+	return memberships, nil
+}
+
+func (r *Repository) CountMembersWithRole(organizationID string, role string) (int64, error) {
+	filter := bson.M{"organizationId": organizationID, "role": role}
+	return r.db.Collection(r.membersCollection).CountDocuments(context.TODO(), filter)
+}
+
+func (r *Repository) UpdateMembershipRole(organizationID string, email string, role string) error {
+	filter := bson.M{"organizationId": organizationID, "email": email}
+	update := bson.M{"$set": bson.M{"role": role}}
+	_, err := r.db.Collection(r.membersCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) DeleteMembership(organizationID string, email string) error {
+	filter := bson.M{"organizationId": organizationID, "email": email}
+	_, err := r.db.Collection(r.membersCollection).DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) SaveInvitation(invitation Invitation) error {
+	_, err := r.db.Collection(r.invitationsCollection).InsertOne(context.TODO(), invitation)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetOpenInvitation finds an invitation that has not been accepted and has not expired.
+func (r *Repository) GetOpenInvitation(code string) (bool, Invitation, error) {
+	var invitation Invitation
+	filter := bson.M{
+		"code":     code,
+		"accepted": bson.M{"$ne": true},
+		"expiry":   bson.M{"$gt": time.Now()},
+	}
+	err := r.db.Collection(r.invitationsCollection).FindOne(context.TODO(), filter).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		return false, Invitation{}, nil
+	}
+	if err != nil {
+		return false, Invitation{}, err
+	}
+	return true, invitation, nil
+}
+
+// MarkInvitationAccepted returns false if the invitation was already accepted by another request.
+func (r *Repository) MarkInvitationAccepted(code string) (bool, error) {
+	filter := bson.M{"code": code, "accepted": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"accepted": true}}
+	result, err := r.db.Collection(r.invitationsCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
@@ -0,0 +1,410 @@
+package organizations
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go-boilerplate/audit"
+	"go-boilerplate/common"
+	"go-boilerplate/emails/organizationinviteemail"
+	"go-boilerplate/logging"
+	"go-boilerplate/user"
+	"strings"
+	"time"
+)
+
+type Services struct {
+	logger                  logging.Logger
+	userRepository          user.Repository
+	organizationsRepository Repository
+	auditServices           audit.Services
+}
+
+func NewInstanceOfOrganizationsServices(logger logging.Logger, userRepository user.Repository, organizationsRepository Repository, auditServices audit.Services) Services {
+	return Services{logger, userRepository, organizationsRepository, auditServices}
+}
+
+// Create creates the organization and makes the signed in user its first owner.
+func (s *Services) Create(ctx context.Context, session user.Session, body CreateOrganizationBody) (Organization, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Create")
+
+	organization := Organization{
+		Name:      strings.Trim(body.Name, " "),
+		CreatedBy: session.Email,
+		Created:   time.Now(),
+	}
+	organizationID, err := s.organizationsRepository.SaveOrganization(organization)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to save organization", err)
+		return Organization{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	err = s.organizationsRepository.SaveMembership(Membership{
+		OrganizationID: organizationID,
+		Email:          session.Email,
+		Role:           RoleOwner,
+		Created:        organization.Created,
+	})
+	if err != nil {
+		s.logger.Warning(ctx, "failed to save owner membership", err)
+		return Organization{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	s.auditServices.Record(ctx, audit.Event{Type: audit.OrganizationCreated, Email: session.Email, Resource: organizationID})
+
+	_, organization, err = s.organizationsRepository.GetOrganization(organizationID)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get organization", err)
+		return Organization{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	return organization, nil
+}
+
+// GetAll lists the organizations the signed in user is a member of.
+func (s *Services) GetAll(ctx context.Context, session user.Session) ([]OrganizationWithRole, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetAll")
+
+	memberships, err := s.organizationsRepository.ListMembershipsByEmail(session.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to list memberships", err)
+		return []OrganizationWithRole{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if len(memberships) == 0 {
+		return []OrganizationWithRole{}, nil
+	}
+
+	roles := map[string]string{}
+	organizationIDs := []string{}
+	for _, membership := range memberships {
+		roles[membership.OrganizationID] = membership.Role
+		organizationIDs = append(organizationIDs, membership.OrganizationID)
+	}
+
+	organizations, err := s.organizationsRepository.ListOrganizations(organizationIDs)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to list organizations", err)
+		return []OrganizationWithRole{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	result := []OrganizationWithRole{}
+	for _, organization := range organizations {
+		result = append(result, OrganizationWithRole{organization, roles[organization.ID.Hex()]})
+	}
+	return result, nil
+}
+
+// GetByID returns the organization and its members. Any member can see this.
+func (s *Services) GetByID(ctx context.Context, session user.Session, organizationID string) (Organization, []Membership, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetByID")
+
+	if _, err := s.getMembership(ctx, organizationID, session.Email); err != nil {
+		return Organization{}, []Membership{}, err
+	}
+
+	found, organization, err := s.organizationsRepository.GetOrganization(organizationID)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get organization", err)
+		return Organization{}, []Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found {
+		return Organization{}, []Membership{}, &common.Error{
+			StatusCode: 404,
+			Message:    "Error: Organization not found",
+		}
+	}
+
+	members, err := s.organizationsRepository.ListMembershipsByOrganization(organizationID)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to list members", err)
+		return Organization{}, []Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	return organization, members, nil
+}
+
+// Invite emails a single use code that lets the recipient join the organization with the given role.
+func (s *Services) Invite(ctx context.Context, session user.Session, organizationID string, body InviteMemberBody) *common.Error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "Invite")
+
+	membership, serviceErr := s.getMembership(ctx, organizationID, session.Email)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !RoleHasPermission(membership.Role, PermissionManageMembers) {
+		s.logger.Warning(ctx, "member is not allowed to invite", errors.New("unauthorized"))
+		return &common.Error{
+			StatusCode: 403,
+		}
+	}
+	if body.Role == RoleOwner && membership.Role != RoleOwner {
+		return &common.Error{
+			StatusCode: 403,
+			Message:    "Error: Only owners can invite owners",
+		}
+	}
+
+	email := body.GetFormattedEmail()
+	alreadyMember, _, err := s.organizationsRepository.GetMembership(organizationID, email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get membership", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if alreadyMember {
+		return &common.Error{
+			StatusCode: 400,
+			Message:    "Error: Already a member",
+		}
+	}
+
+	_, organization, err := s.organizationsRepository.GetOrganization(organizationID)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get organization", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	now := time.Now()
+	invitation := Invitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           body.Role,
+		Code:           uuid.New().String(),
+		InvitedBy:      session.Email,
+		Created:        now,
+		Expiry:         now.AddDate(0, 0, 7), // Expires in 7 days
+	}
+	err = s.organizationsRepository.SaveInvitation(invitation)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to save invitation", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	inviterName := session.Email
+	found, inviter, err := s.userRepository.GetUserByEmail(session.Email)
+	if err == nil && found && inviter.Name != "" {
+		inviterName = inviter.Name
+	}
+	inviteeName := "there"
+	found, invitee, err := s.userRepository.GetUserByEmail(email)
+	if err == nil && found {
+		inviteeName = invitee.Greeting()
+	}
+
+	err = organizationinviteemail.SendOrganizationInviteEmail(inviteeName, email, inviterName, organization.Name, invitation.Code)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to send the invitation email", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	s.auditServices.Record(ctx, audit.Event{Type: audit.OrganizationInvited, Email: email, Actor: session.Email, Resource: organizationID, Metadata: map[string]string{"role": body.Role}})
+	return nil
+}
+
+// AcceptInvitation adds the signed in user to the organization. The invitation must have been sent to their email.
+func (s *Services) AcceptInvitation(ctx context.Context, session user.Session, body AcceptInvitationBody) (Membership, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "AcceptInvitation")
+
+	found, invitation, err := s.organizationsRepository.GetOpenInvitation(body.Code)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get invitation", err)
+		return Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found || invitation.Email != session.Email {
+		s.logger.Warning(ctx, "invalid invitation code", errors.New("unauthorized"))
+		return Membership{}, &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	accepted, err := s.organizationsRepository.MarkInvitationAccepted(body.Code)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to mark invitation accepted", err)
+		return Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !accepted {
+		return Membership{}, &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	alreadyMember, membership, err := s.organizationsRepository.GetMembership(invitation.OrganizationID, session.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get membership", err)
+		return Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if alreadyMember {
+		return membership, nil
+	}
+
+	membership = Membership{
+		OrganizationID: invitation.OrganizationID,
+		Email:          session.Email,
+		Role:           invitation.Role,
+		Created:        time.Now(),
+	}
+	err = s.organizationsRepository.SaveMembership(membership)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to save membership", err)
+		return Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	s.auditServices.Record(ctx, audit.Event{Type: audit.OrganizationJoined, Email: session.Email, Resource: invitation.OrganizationID, Metadata: map[string]string{"role": invitation.Role}})
+	return membership, nil
+}
+
+// UpdateMember changes a member's role. Only owners can add or remove the owner role and there must always be
+// at least one owner.
+func (s *Services) UpdateMember(ctx context.Context, session user.Session, organizationID string, email string, body UpdateMemberBody) *common.Error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "UpdateMember")
+
+	membership, serviceErr := s.getMembership(ctx, organizationID, session.Email)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !RoleHasPermission(membership.Role, PermissionManageMembers) {
+		return &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	target, serviceErr := s.getMembership(ctx, organizationID, email)
+	if serviceErr != nil {
+		if serviceErr.StatusCode == 404 {
+			serviceErr.Message = "Error: Member not found"
+		}
+		return serviceErr
+	}
+	if (target.Role == RoleOwner || body.Role == RoleOwner) && membership.Role != RoleOwner {
+		return &common.Error{
+			StatusCode: 403,
+			Message:    "Error: Only owners can change owners",
+		}
+	}
+	if target.Role == RoleOwner && body.Role != RoleOwner {
+		if serviceErr := s.ensureAnotherOwner(ctx, organizationID); serviceErr != nil {
+			return serviceErr
+		}
+	}
+
+	err := s.organizationsRepository.UpdateMembershipRole(organizationID, target.Email, body.Role)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to update role", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	s.auditServices.Record(ctx, audit.Event{Type: audit.OrganizationRoleChanged, Email: target.Email, Actor: session.Email, Resource: organizationID, Metadata: map[string]string{"from": target.Role, "to": body.Role}})
+	return nil
+}
+
+// RemoveMember removes a member. Members can always remove themselves (leave).
+func (s *Services) RemoveMember(ctx context.Context, session user.Session, organizationID string, email string) *common.Error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "RemoveMember")
+
+	membership, serviceErr := s.getMembership(ctx, organizationID, session.Email)
+	if serviceErr != nil {
+		return serviceErr
+	}
+
+	target, serviceErr := s.getMembership(ctx, organizationID, email)
+	if serviceErr != nil {
+		if serviceErr.StatusCode == 404 {
+			serviceErr.Message = "Error: Member not found"
+		}
+		return serviceErr
+	}
+
+	if target.Email != session.Email {
+		if !RoleHasPermission(membership.Role, PermissionManageMembers) {
+			return &common.Error{
+				StatusCode: 403,
+			}
+		}
+		if target.Role == RoleOwner && membership.Role != RoleOwner {
+			return &common.Error{
+				StatusCode: 403,
+				Message:    "Error: Only owners can remove owners",
+			}
+		}
+	}
+	if target.Role == RoleOwner {
+		if serviceErr := s.ensureAnotherOwner(ctx, organizationID); serviceErr != nil {
+			return serviceErr
+		}
+	}
+
+	err := s.organizationsRepository.DeleteMembership(organizationID, target.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to delete membership", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	s.auditServices.Record(ctx, audit.Event{Type: audit.OrganizationRemoved, Email: target.Email, Actor: session.Email, Resource: organizationID})
+	return nil
+}
+
+// getMembership returns a 404 when the email is not a member, so non-members can't tell if an organization exists.
+func (s *Services) getMembership(ctx context.Context, organizationID string, email string) (Membership, *common.Error) {
+	found, membership, err := s.organizationsRepository.GetMembership(organizationID, email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get membership", err)
+		return Membership{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found {
+		return Membership{}, &common.Error{
+			StatusCode: 404,
+			Message:    "Error: Organization not found",
+		}
+	}
+	return membership, nil
+}
+
+func (s *Services) ensureAnotherOwner(ctx context.Context, organizationID string) *common.Error {
+	owners, err := s.organizationsRepository.CountMembersWithRole(organizationID, RoleOwner)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to count owners", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if owners < 2 {
+		return &common.Error{
+			StatusCode: 400,
+			Message:    "Error: An organization must have at least one owner",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "os"
+
+// GetLocalStoragePath returns the directory uploaded files are kept in. Defaults to uploads in the working
+// directory.
+func GetLocalStoragePath() string {
+	path := os.Getenv("STORAGE_PATH")
+	if path == "" {
+		return "uploads"
+	}
+	return path
+}
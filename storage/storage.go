@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("Error: File not found")
+var ErrInvalidKey = errors.New("Error: Invalid file key")
+
+// Storage keeps uploaded files under keys chosen by the caller, like cars/<carId>/documents/<documentId>. Parts
+// of a key are separated by / whatever the implementation.
+type Storage interface {
+	// Save writes the whole content under the key, replacing any file already there, and returns its size.
+	Save(key string, content io.Reader) (int64, error)
+	// Open returns ErrNotFound when there is no file under the key. The caller closes the file.
+	Open(key string) (io.ReadCloser, error)
+	// Delete does nothing when there is no file under the key.
+	Delete(key string) error
+}
@@ -0,0 +1,32 @@
+package user
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetPasswordHistoryLimit returns how many password hashes are kept per user to stop reuse. Defaults to 5.
+func GetPasswordHistoryLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_LIMIT"))
+	if err != nil || limit < 1 {
+		return 5
+	}
+	return limit
+}
+
+// GetPasswordMaxAge returns how long a password is valid for before the user is forced to change it. Zero
+// (the default) means passwords never expire.
+func GetPasswordMaxAge() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_AGE_DAYS"))
+	if err != nil || days < 1 {
+		return 0
+	}
+	return time.Hour * 24 * time.Duration(days)
+}
+
+// GetIPLocationURL returns the URL IP addresses are looked up at, with {ip} standing in for the address. Lookups
+// are off when it is not set, since they send the user's IP address to another service.
+func GetIPLocationURL() string {
+	return os.Getenv("IP_LOCATION_URL")
+}
@@ -24,8 +24,14 @@ func (r *ForgotPasswordRepository) Save(forgotPassword ForgotPasswordCode) error
 	return nil
 }
 
+// Exists checks for a code that has not been used and has not expired.
 func (r *ForgotPasswordRepository) Exists(email string, code string) (bool, error) {
-	filter := bson.M{"email": email, "code": code}
+	filter := bson.M{
+		"email": email,
+		"code": code,
+		"completed": bson.M{"$ne": true},
+		"expiry": bson.M{"$gt": time.Now()},
+	}
 	count, err := r.db.Collection(r.forgotPasswordCollection).CountDocuments(context.TODO(), filter)
 	if err != nil {
 		return false, err
@@ -33,12 +39,32 @@ func (r *ForgotPasswordRepository) Exists(email string, code string) (bool, erro
 	return count > 0, nil
 }
 
-func (r *ForgotPasswordRepository) MarkCodeAsComplete(email string, code string) error {
-	filter := bson.M{"email": email, "code": code}
-	update := bson.M{"$set": bson.M{"expiry": time.Now()}}
-	_, err := r.db.Collection(r.forgotPasswordCollection).UpdateOne(context.TODO(), filter, update)
+// MarkCodeAsComplete marks the code as used. The filter matches the same way as Exists so only one request can
+// complete a code, the boolean is false when the code was already used or expired.
+func (r *ForgotPasswordRepository) MarkCodeAsComplete(email string, code string) (bool, error) {
+	now := time.Now()
+	filter := bson.M{
+		"email": email,
+		"code": code,
+		"completed": bson.M{"$ne": true},
+		"expiry": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"completed": true, "expiry": now}}
+	result, err := r.db.Collection(r.forgotPasswordCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// ExpireAllCodes expires every outstanding code for the email, used once the password has been reset.
+func (r *ForgotPasswordRepository) ExpireAllCodes(email string) error {
+	now := time.Now()
+	filter := bson.M{"email": email, "expiry": bson.M{"$gt": now}}
+	update := bson.M{"$set": bson.M{"expiry": now}}
+	_, err := r.db.Collection(r.forgotPasswordCollection).UpdateMany(context.TODO(), filter, update)
 	if err != nil {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
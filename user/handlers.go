@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/google/uuid"
 	"github.com/mssola/user_agent"
+	"go-boilerplate/audit"
 	"go-boilerplate/common"
 
 	// "fmt"
@@ -23,6 +24,18 @@ func NewInstanceOfUserHandlers(logger logging.Logger, userServices Services) *Ha
 	return &Handlers{logger, userServices}
 }
 
+func (u *Handlers) GetSession(c *gin.Context) (Session, bool) {
+	i, exists := c.Get("session")
+	if !exists {
+		return Session{}, false
+	}
+	session, ok := i.(Session)
+	if !ok {
+		return Session{}, false
+	}
+	return session, true
+}
+
 func (u *Handlers) SignIn(c *gin.Context) {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, logging.CtxDomain, "user")
@@ -33,6 +46,7 @@ func (u *Handlers) SignIn(c *gin.Context) {
 	clientIP := c.ClientIP()
 
 	ctx = context.WithValue(ctx, logging.CtxClientIP, clientIP)
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	// Capture User Agent header
 	var userAgent *user_agent.UserAgent
@@ -53,13 +67,13 @@ func (u *Handlers) SignIn(c *gin.Context) {
 		return
 	}
 
-	token, sessionLocked, err := u.userServices.SignIn(ctx, userAgent, clientIP, body)
+	result, err := u.userServices.SignIn(ctx, userAgent, clientIP, body)
 	if err != nil {
 		common.ReturnErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Signed in", "token": token, "sessionLocked": sessionLocked})
+	c.JSON(200, gin.H{"message": "Signed in", "token": result.Token, "sessionLocked": result.SessionLocked, "passwordResetRequired": result.PasswordResetRequired})
 	return
 }
 
@@ -73,6 +87,7 @@ func (u *Handlers) SignUp(c *gin.Context) {
 	clientIP := c.ClientIP()
 
 	ctx = context.WithValue(ctx, logging.CtxClientIP, clientIP)
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	// Capture User Agent header
 	var userAgent *user_agent.UserAgent
@@ -92,13 +107,13 @@ func (u *Handlers) SignUp(c *gin.Context) {
 		return
 	}
 
-	token, sessionLocked, err := u.userServices.SignUp(ctx, userAgent, clientIP, body)
+	result, err := u.userServices.SignUp(ctx, userAgent, clientIP, body)
 	if err != nil {
 		common.ReturnErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Signed up", "token": token, "sessionLocked": sessionLocked})
+	c.JSON(200, gin.H{"message": "Signed up", "token": result.Token, "sessionLocked": result.SessionLocked, "passwordResetRequired": result.PasswordResetRequired})
 	return
 }
 
@@ -108,6 +123,7 @@ func (u *Handlers) LogOut(c *gin.Context) {
 	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "LogOut")
 	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
 	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	authToken := c.Request.Header.Get("Authorization")
 	authToken = strings.ReplaceAll(authToken, "Bearer ", "")
@@ -130,6 +146,7 @@ func (u *Handlers) UnlockSession(c *gin.Context) {
 	// Capture IP
 	clientIP := c.ClientIP()
 	ctx = context.WithValue(ctx, logging.CtxClientIP, clientIP)
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	// Get auth token
 	if c.Request.Header["Authorization"] == nil || len(c.Request.Header["Authorization"]) == 0 {
@@ -185,6 +202,7 @@ func (u *Handlers) SendForgotPassword(c *gin.Context) {
 	// Capture IP
 	clientIP := c.ClientIP()
 	ctx = context.WithValue(ctx, logging.CtxClientIP, clientIP)
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
 
 	// Get code from request body
 	var body SendForgotPasswordBody
@@ -220,6 +238,13 @@ func (u *Handlers) ForgotPassword(c *gin.Context) {
 	// Capture IP
 	clientIP := c.ClientIP()
 	ctx = context.WithValue(ctx, logging.CtxClientIP, clientIP)
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	// Capture User Agent header
+	var userAgent *user_agent.UserAgent
+	if c.Request.Header["User-Agent"] != nil && len(c.Request.Header["User-Agent"]) > 0 {
+		userAgent = user_agent.New(c.Request.Header["User-Agent"][0])
+	}
 
 	// Get code from request body
 	var body ResetForgotPasswordBody
@@ -236,7 +261,7 @@ func (u *Handlers) ForgotPassword(c *gin.Context) {
 	}
 
 	// Call the service
-	err := u.userServices.ForgotPassword(ctx, clientIP, body)
+	err := u.userServices.ForgotPassword(ctx, userAgent, clientIP, body)
 	if err != nil {
 		common.ReturnErrorResponse(c, err)
 		return
@@ -245,4 +270,136 @@ func (u *Handlers) ForgotPassword(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "Password has been reset"})
 	return
 
-}
\ No newline at end of file
+}
+
+func (u *Handlers) ChangePassword(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "user")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "ChangePassword")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	var body ChangePasswordBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.logger.Warning(ctx, "invalid request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400})
+		return
+	}
+
+	if err := body.Validate(); err != nil {
+		u.logger.Warning(ctx, "validation failed on request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	err := u.userServices.ChangePassword(ctx, session, body)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Password changed"})
+	return
+}
+
+func (u *Handlers) GetNotificationPreferences(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "user")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetNotificationPreferences")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	preferences, err := u.userServices.GetNotificationPreferences(ctx, session)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Notification preferences retrieved", "notificationPreferences": preferences})
+	return
+}
+
+func (u *Handlers) UpdateNotificationPreferences(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "user")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "UpdateNotificationPreferences")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	var body UpdateNotificationPreferencesBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		u.logger.Warning(ctx, "invalid request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400})
+		return
+	}
+
+	if err := body.Validate(); err != nil {
+		u.logger.Warning(ctx, "validation failed on request body", err)
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	preferences, err := u.userServices.UpdateNotificationPreferences(ctx, session, body)
+	if err != nil {
+		common.ReturnErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Notification preferences updated", "notificationPreferences": preferences})
+	return
+}
+
+func (u *Handlers) GetActivity(c *gin.Context) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, logging.CtxDomain, "user")
+	ctx = context.WithValue(ctx, logging.CtxHandlerMethod, "GetActivity")
+	ctx = context.WithValue(ctx, logging.CtxRequestID, uuid.New().String())
+	ctx = context.WithValue(ctx, logging.CtxClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, logging.CtxUserAgent, c.Request.UserAgent())
+
+	session, exists := u.GetSession(c)
+	if !exists {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 403})
+		return
+	}
+
+	query, err := audit.ParseListEventsQuery(c)
+	if err != nil {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+	if err := query.Valid(); err != nil {
+		common.ReturnErrorResponse(c, &common.Error{StatusCode: 400, Message: err.Error()})
+		return
+	}
+
+	events, total, serviceErr := u.userServices.GetActivity(ctx, session, query)
+	if serviceErr != nil {
+		common.ReturnErrorResponse(c, serviceErr)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Activity retrieved", "events": events, "page": query.Page, "limit": query.Limit, "total": total})
+	return
+}
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const locationTimeout = 3 * time.Second
+
+var locationClient = &http.Client{Timeout: locationTimeout}
+
+// ipLocation is the response of the lookup service. The fields are those of ip-api.com, which most lookup
+// services can be set up to match.
+type ipLocation struct {
+	Status     string  `json:"status"`
+	Country    string  `json:"country"`
+	RegionName string  `json:"regionName"`
+	City       string  `json:"city"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+}
+
+// LookupLocation finds where the IP address is using the service at IP_LOCATION_URL. Private and loopback
+// addresses are never looked up.
+func LookupLocation(address string) (IP, error) {
+	lookupURL := GetIPLocationURL()
+	ip := net.ParseIP(address)
+	if lookupURL == "" || ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() {
+		return IP{Address: address}, nil
+	}
+
+	response, err := locationClient.Get(strings.ReplaceAll(lookupURL, "{ip}", url.PathEscape(address)))
+	if err != nil {
+		return IP{Address: address}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return IP{Address: address}, errors.New("Error: IP location lookup returned " + response.Status)
+	}
+
+	var location ipLocation
+	if err := json.NewDecoder(response.Body).Decode(&location); err != nil {
+		return IP{Address: address}, err
+	}
+	if location.Status == "fail" || (location.Country == "" && location.City == "") {
+		return IP{Address: address}, nil
+	}
+	return IP{
+		Address:       address,
+		LocationFound: true,
+		Latitude:      location.Lat,
+		Longitude:     location.Lon,
+		Country:       location.Country,
+		Region:        location.RegionName,
+		City:          location.City,
+	}, nil
+}
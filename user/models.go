@@ -20,6 +20,9 @@ type User struct {
 	InvalidIPs []IP `json:"invalidIPs" bson:"invalidIPs"`
 	AccountLocked     bool      `json:"accountLocked" bson:"accountLocked"` // Stop new sign ins from happening
 	KnownDevices      []Device  `json:"knownDevices" bson:"knownDevices"`
+	PasswordHistory   []string  `json:"-" bson:"passwordHistory"` // Most recent password hashes, newest last, used to stop password reuse
+	PasswordChanged   time.Time `json:"passwordChanged" bson:"passwordChanged"`
+	NotificationPreferences NotificationPreferences `json:"notificationPreferences" bson:"notificationPreferences"`
 }
 
 func (u *User) Greeting() string {
@@ -29,6 +32,43 @@ func (u *User) Greeting() string {
 	return "there"
 }
 
+// PasswordExpired returns true when the password is older than the max age. A max age of zero disables
+// the check. Users created before password changes were tracked fall back to their created date.
+func (u *User) PasswordExpired(maxAge time.Duration) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	lastChanged := u.PasswordChanged
+	if lastChanged.IsZero() {
+		lastChanged = u.Created
+	}
+	return time.Now().After(lastChanged.Add(maxAge))
+}
+
+// LocatedIP returns the IP address with its location if one has been stored against the user.
+func (u *User) LocatedIP(ipAddress string) (IP, bool) {
+	for _, ip := range append(u.TrustedIPs, u.InvalidIPs...) {
+		if ip.Address == ipAddress && ip.LocationFound {
+			return ip, true
+		}
+	}
+	return IP{}, false
+}
+
+// HasKnownDevice checks if the device matches a valid device the user has signed in with before. A browser
+// update is treated as a new device.
+func (u *User) HasKnownDevice(device Device) bool {
+	for _, knownDevice := range u.KnownDevices {
+		if knownDevice.ValidDevice &&
+			knownDevice.Browser == device.Browser &&
+			knownDevice.BrowserVersion == device.BrowserVersion &&
+			knownDevice.OperatingSystem == device.OperatingSystem {
+			return true
+		}
+	}
+	return false
+}
+
 type IP struct {
 	Address string `json:"address" bson:"address"`
 	LocationFound bool `json:"locationFound" bson:"locationFound"` // Boolean flag that indicates other location based attributes are set
@@ -39,6 +79,19 @@ type IP struct {
 	City string `json:"city" bson:"city"`
 }
 
+func (i *IP) Location() string {
+	parts := []string{}
+	for _, part := range []string{i.City, i.Region, i.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "Unknown"
+	}
+	return strings.Join(parts, ", ")
+}
+
 func (u *User) HasTrustedIP(ipAddress string) bool {
 	for _, ip := range u.TrustedIPs {
 		if ip.Address == ipAddress {
@@ -71,6 +124,64 @@ type Device struct {
 	ValidDevice     bool   `json:"validDevice" bson:"validDevice"` // Starts as true and user can change to false.
 }
 
+func (d *Device) BrowserDescription() string {
+	if d.Browser == "" {
+		return "Unknown"
+	}
+	return strings.Trim(d.Browser+" "+d.BrowserVersion, " ")
+}
+
+func (d *Device) OperatingSystemDescription() string {
+	if d.OperatingSystem == "" {
+		return "Unknown"
+	}
+	return d.OperatingSystem
+}
+
+// Notification preference values. Empty is treated as NotifyAlways so existing users keep getting emails.
+const (
+	NotifyAlways    = "always"
+	NotifyNewDevice = "newDevice"
+	NotifyNever     = "never"
+)
+
+// NotificationPreferences controls which security emails the user receives.
+type NotificationPreferences struct {
+	SignIn          string `json:"signIn" bson:"signIn"`
+	AccountLocked   string `json:"accountLocked" bson:"accountLocked"`
+	PasswordChanged string `json:"passwordChanged" bson:"passwordChanged"`
+}
+
+// WithDefaults fills in any preference that has not been set.
+func (p NotificationPreferences) WithDefaults() NotificationPreferences {
+	if p.SignIn == "" {
+		p.SignIn = NotifyAlways
+	}
+	if p.AccountLocked == "" {
+		p.AccountLocked = NotifyAlways
+	}
+	if p.PasswordChanged == "" {
+		p.PasswordChanged = NotifyAlways
+	}
+	return p
+}
+
+// ShouldNotify returns true when an email should be sent for the preference.
+func ShouldNotify(preference string, newDevice bool) bool {
+	switch preference {
+	case NotifyNever:
+		return false
+	case NotifyNewDevice:
+		return newDevice
+	default:
+		return true
+	}
+}
+
+func isValidNotificationPreference(preference string) bool {
+	return preference == NotifyAlways || preference == NotifyNewDevice || preference == NotifyNever
+}
+
 type Session struct {
 	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Email   string             `json:"email" bson:"email"`
@@ -79,6 +190,15 @@ type Session struct {
 	Locked     bool               `json:"locked" bson:"locked"`
 	UnlockCode string             `json:"unlockCode" bson:"unlockCode"` // Second layer of security, on suspicious signs in, emails code to confirm
 	Device Device `json:"device" bson:"device,omitempty"`
+	PasswordResetRequired bool `json:"passwordResetRequired" bson:"passwordResetRequired"` // Password is past its max age, only a password change is allowed
+}
+
+// SignInResult is returned from sign in and sign up so the handler can tell the client what state the
+// new session is in.
+type SignInResult struct {
+	Token                 string
+	SessionLocked         bool
+	PasswordResetRequired bool
 }
 
 type SignInBody struct {
@@ -145,10 +265,55 @@ func (b *ResetForgotPasswordBody) GetFormattedEmail() string {
 	return email
 }
 
+type ChangePasswordBody struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (b *ChangePasswordBody) Validate() error {
+	if b.CurrentPassword == "" {
+		return errors.New("current password is required")
+	}
+	if b.NewPassword == "" {
+		return errors.New("new password is required")
+	}
+	return nil
+}
+
+// UpdateNotificationPreferencesBody only changes the preferences that are provided.
+type UpdateNotificationPreferencesBody struct {
+	SignIn          string `json:"signIn"`
+	AccountLocked   string `json:"accountLocked"`
+	PasswordChanged string `json:"passwordChanged"`
+}
+
+func (b *UpdateNotificationPreferencesBody) Validate() error {
+	for _, preference := range []string{b.SignIn, b.AccountLocked, b.PasswordChanged} {
+		if preference != "" && !isValidNotificationPreference(preference) {
+			return errors.New("preference must be one of: always, newDevice, never")
+		}
+	}
+	return nil
+}
+
+func (b *UpdateNotificationPreferencesBody) Apply(preferences NotificationPreferences) NotificationPreferences {
+	if b.SignIn != "" {
+		preferences.SignIn = b.SignIn
+	}
+	if b.AccountLocked != "" {
+		preferences.AccountLocked = b.AccountLocked
+	}
+	if b.PasswordChanged != "" {
+		preferences.PasswordChanged = b.PasswordChanged
+	}
+	return preferences
+}
+
 type ForgotPasswordCode struct {
 	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Email string `json:"email" bson:"email"`
 	Code string `json:"-" bson:"code"`
 	Created time.Time `json:"created" bson:"created"`
 	Expiry time.Time `json:"expiry" bson:"expiry"`
-}
\ No newline at end of file
+	Completed bool `json:"completed" bson:"completed"` // Codes are single use
+}
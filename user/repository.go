@@ -101,6 +101,18 @@ func (u *Repository) MarkSessionAsExpired(authToken string) error {
 	return nil
 }
 
+// ExpireAllSessions signs the user out everywhere by expiring all of their active sessions.
+func (u *Repository) ExpireAllSessions(email string) error {
+	now := time.Now()
+	filter := bson.M{"email": email, "expiry": bson.M{"$gt": now}}
+	update := bson.M{"$set": bson.M{"expiry": now}}
+	_, err := u.db.Collection(u.sessionsCollection).UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (u *Repository) UpdateAccountLocked(email string, accountLock bool) error {
 	filter := bson.M{"email": email}
 	update := bson.M{"$set": bson.M{"accountLocked": accountLock}}
@@ -148,12 +160,42 @@ func (u *Repository) UnlockSession(authToken string) error {
 	return nil
 }
 
-func (u *Repository) UpdatePassword(email string, newPassword string) error {
+func (u *Repository) UpdateNotificationPreferences(email string, preferences NotificationPreferences) error {
+	filter := bson.M{"email": email}
+	update := bson.M{"$set": bson.M{"notificationPreferences": preferences}}
+	_, err := u.db.Collection(u.usersCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdatePassword sets the new password hash and adds it to the password history, only keeping the most
+// recent historyLimit hashes (Docs: https://docs.mongodb.com/manual/reference/operator/update/slice/).
+func (u *Repository) UpdatePassword(email string, newPassword string, historyLimit int) error {
 	filter := bson.M{"email": email}
-	update := bson.M{"$set": bson.M{"password": newPassword}}
+	update := bson.M{
+		"$set": bson.M{"password": newPassword, "passwordChanged": time.Now()},
+		"$push": bson.M{
+			"passwordHistory": bson.M{
+				"$each":  []string{newPassword},
+				"$slice": -historyLimit,
+			},
+		},
+	}
 	_, err := u.db.Collection(u.usersCollection).UpdateOne(context.TODO(), filter, update)
 	if err != nil {
 		return err
 	}
 	return nil
+}
+
+func (u *Repository) ClearPasswordResetRequired(email string) error {
+	filter := bson.M{"email": email, "passwordResetRequired": true}
+	update := bson.M{"$set": bson.M{"passwordResetRequired": false}}
+	_, err := u.db.Collection(u.sessionsCollection).UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
 }
\ No newline at end of file
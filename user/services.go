@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/mssola/user_agent"
+	"go-boilerplate/audit"
 	"go-boilerplate/common"
 	"go-boilerplate/emails/accountlockemail"
 	"go-boilerplate/emails/forgotpasswordemail"
+	"go-boilerplate/emails/passwordchangedemail"
 	"go-boilerplate/emails/sessionunlockemail"
 	"go-boilerplate/emails/signinemail"
 	"go-boilerplate/emails/verifyemail"
@@ -25,20 +27,21 @@ type Services struct {
 	logger         logging.Logger
 	userRepository Repository
 	forgotPasswordRepository ForgotPasswordRepository
+	auditServices  audit.Services
 }
 
 type ServiceContract interface {
-	SignUp(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignUpBody) (string, bool, *common.Error)
-	SignIn(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignInBody) (string, bool, *common.Error)
+	SignUp(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignUpBody) (SignInResult, *common.Error)
+	SignIn(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignInBody) (SignInResult, *common.Error)
 	LogOut(authToken string) error
 }
 
-func NewInstanceOfUserServices(logger logging.Logger, userRepository Repository, forgotPasswordRepository ForgotPasswordRepository) Services {
-	return Services{logger, userRepository, forgotPasswordRepository}
+func NewInstanceOfUserServices(logger logging.Logger, userRepository Repository, forgotPasswordRepository ForgotPasswordRepository, auditServices audit.Services) Services {
+	return Services{logger, userRepository, forgotPasswordRepository, auditServices}
 }
 
 // SignUp signs up the new account (or signs in the user).
-func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignUpBody) (string, bool, *common.Error) {
+func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignUpBody) (SignInResult, *common.Error) {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "SignUp")
 
 	emailLowerCase := strings.ToLower(body.Email)
@@ -46,7 +49,7 @@ func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent,
 
 	// Verify password meets sign up requirements
 	if !s.isValidPassword(ctx, body.Password) {
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 400,
 			Message: "error: Your password does not meet requirements.",
 		}
@@ -55,7 +58,7 @@ func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent,
 	// Check for user
 	userExists, err := s.userRepository.DoesUserExist(emailTrimmed)
 	if err != nil {
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 500,
 		}
 	}
@@ -66,21 +69,7 @@ func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent,
 	encryptedPassword, err := s.getEncryptedPassword(body.Password)
 
 	// Save the device they are signing up as a known device
-	engine, engineVersion := userAgent.Engine()
-	browserName, browserVersion := userAgent.Browser()
-	knownDevices := []Device{{
-		Name: "Sign Up Device",
-		Mobile: userAgent.Mobile(),
-		Bot: userAgent.Bot(),
-		Mozilla: userAgent.Mozilla(),
-		Platform: userAgent.Platform(),
-		OperatingSystem: userAgent.OS(),
-		Engine: engine,
-		EngineVersion: engineVersion,
-		Browser: browserName,
-		BrowserVersion: browserVersion,
-		ValidDevice: true,
-	}}
+	knownDevices := []Device{s.getDevice("Sign Up Device", userAgent)}
 
 	// Create verification code
 	verificationCode := uuid.New().String()
@@ -92,18 +81,20 @@ func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent,
 		Email:    emailTrimmed,
 		Password: encryptedPassword,
 		Name:     body.Name,
-		Created:  time.Now(),
+		Created:  now,
 		VerifiedEmail: false,
 		VerificationCode: verificationCode,
 		VerificationExpiryTime: verificationExpiry,
 		TrustedIPs: []IP{},
 		InvalidIPs: []IP{},
 		KnownDevices: knownDevices,
+		PasswordHistory: []string{encryptedPassword},
+		PasswordChanged: now,
 	}
 	err = s.userRepository.SaveUser(newUser)
 	if err != nil {
 		s.logger.Warning(ctx, "failed to save user", err)
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 500,
 		}
 	}
@@ -112,7 +103,7 @@ func (s *Services) SignUp(ctx context.Context, userAgent *user_agent.UserAgent,
 	err = verifyemail.SendVerifyEmail(newUser.Greeting(), newUser.Email, verificationCode)
 	if err != nil {
 		s.logger.Warning(ctx, "failed to send verify email", err)
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 500,
 		}
 	}
@@ -165,7 +156,7 @@ func (s *Services) getEncryptedPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
-func (s *Services) SignIn(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignInBody) (string, bool, *common.Error) {
+func (s *Services) SignIn(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body SignInBody) (SignInResult, *common.Error) {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "SignIn")
 
 	emailLowerCase := strings.ToLower(body.Email)
@@ -173,35 +164,38 @@ func (s *Services) SignIn(ctx context.Context, userAgent *user_agent.UserAgent,
 	return s.signIn(ctx, false, userAgent, currentIP, emailTrimmed, body.Password)
 }
 
-func (s *Services) signIn(ctx context.Context, isSignUp bool, userAgent *user_agent.UserAgent, currentIP string, email string, password string) (string, bool, *common.Error) {
+func (s *Services) signIn(ctx context.Context, isSignUp bool, userAgent *user_agent.UserAgent, currentIP string, email string, password string) (SignInResult, *common.Error) {
 	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "signIn"))
 
 	// Grab user
 	found, user, err := s.userRepository.GetUserByEmail(email)
 	if err != nil {
 		s.logger.Warning(ctx, "failed to get user by email", err)
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 500,
 		}
 	}
 
 	if !found {
 		s.logger.Warning(ctx, "failed to find user", errors.New("error: unauthorized"))
-		return "", false, &common.Error{
+		s.auditServices.Record(ctx, audit.Event{Type: audit.SignInFailed, Email: email, Metadata: map[string]string{"reason": "userNotFound"}})
+		return SignInResult{}, &common.Error{
 			StatusCode: 403,
 		}
 	}
 
 	if !s.isUsersPassword(user.Password, password) {
 		s.logger.Warning(ctx, "invalid password", errors.New("error: unauthorized"))
-		return "", false, &common.Error{
+		s.auditServices.Record(ctx, audit.Event{Type: audit.SignInFailed, Email: email, Metadata: map[string]string{"reason": "invalidPassword"}})
+		return SignInResult{}, &common.Error{
 			StatusCode: 403,
 		}
 	}
 
 	// They now have a valid signed in
 	if user.AccountLocked {
-		return "", false, &common.Error{
+		s.auditServices.Record(ctx, audit.Event{Type: audit.SignInFailed, Email: email, Metadata: map[string]string{"reason": "accountLocked"}})
+		return SignInResult{}, &common.Error{
 			StatusCode: 403,
 			Message: "error: Account has been locked. Please reset password.",
 		}
@@ -210,22 +204,32 @@ func (s *Services) signIn(ctx context.Context, isSignUp bool, userAgent *user_ag
 	// Check if trusted ip, level of legitamacy of the sign in
 	lockSession, invalidSession, err := s.validateSignIn(ctx, isSignUp, user, userAgent, currentIP)
 	if err != nil {
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 500,
 		}
 	}
 
+	device := s.getDevice("", userAgent)
+	isNewDevice := !user.HasKnownDevice(device)
+
 	if invalidSession {
-		err := s.lockUserAccount(user)
+		err := s.lockUserAccount(ctx, user, isNewDevice)
 		if err != nil {
 			// Ignore the failure but worth notifying your dev team for
 			s.logger.Error(ctx, "failed to lock the user account", err)
 		}
-		return "", false, &common.Error{
+		s.auditServices.Record(ctx, audit.Event{Type: audit.SignInFailed, Email: email, Metadata: map[string]string{"reason": "invalidSession"}})
+		return SignInResult{}, &common.Error{
 			StatusCode: 403,
 		}
 	}
 
+	// Passwords past their max age still sign in, but the session can only be used to change the password
+	passwordResetRequired := user.PasswordExpired(GetPasswordMaxAge())
+	if passwordResetRequired {
+		s.logger.Info(ctx, "Password is past max age, session requires a password change")
+	}
+
 	// Create session
 	now := time.Now()
 	expiryDate := now.AddDate(0, 0, 1)
@@ -235,55 +239,95 @@ func (s *Services) signIn(ctx context.Context, isSignUp bool, userAgent *user_ag
 		Expiry:  expiryDate,
 		Locked: lockSession,
 		UnlockCode: uuid.New().String(),
+		PasswordResetRequired: passwordResetRequired,
+		Device: device,
 	}
 
 	// Save the session
 	token, err := s.userRepository.SaveSession(newSession)
 	if err != nil {
 		s.logger.Warning(ctx, "failed to save session", err)
-		return "", false, &common.Error{
+		return SignInResult{}, &common.Error{
 			StatusCode: 500,
 		}
 	}
 
+	s.auditServices.Record(ctx, audit.Event{Type: audit.SignInSucceeded, Email: email})
+
 	if lockSession {
+		s.auditServices.Record(ctx, audit.Event{Type: audit.SessionLocked, Email: email})
+
 		// Session has been locked. Send the user an email with a code to unlock it.
 		s.logger.Info(ctx, "Session has been marked as locked, sending an email with the unlock code")
 		err = sessionunlockemail.SendSessionUnLockEmail(user.Greeting(), user.Email, newSession.UnlockCode)
 		if err != nil {
 			s.logger.Warning(ctx, "failed to send session unlock email", err)
-			return "", false, &common.Error{
+			return SignInResult{}, &common.Error{
 				StatusCode: 500,
 			}
 		}
 	} else {
+		// Where the IP is, from what is stored on the user or else looked up
+		ipDetails, located := user.LocatedIP(currentIP)
+		if !located {
+			ipDetails, err = LookupLocation(currentIP)
+			if err != nil {
+				s.logger.Warning(ctx, "failed to look up the IP location", err)
+			}
+		}
+
 		// Send sign in email (General sign in and not locked accounts)
-		err = signinemail.SendSignInEmail("there", email, currentIP, "<browser>", "<os>") // TODO - Add in the actual values
-		if err != nil {
-			// Ignore the failure. This is my decision since it doesnt stop the user from signing
-			// into their account. However, sending a sign in email is another layer of security.
-			// You can return a 500 here if you want to make sure the email goes through.
-			s.logger.Warning(ctx, "failed to send sign in email", err)
+		if ShouldNotify(user.NotificationPreferences.SignIn, isNewDevice) {
+			err = signinemail.SendSignInEmail(user.Greeting(), email, currentIP, ipDetails.Location(), device.BrowserDescription(), device.OperatingSystemDescription())
+			if err != nil {
+				// Ignore the failure. This is my decision since it doesnt stop the user from signing
+				// into their account. However, sending a sign in email is another layer of security.
+				// You can return a 500 here if you want to make sure the email goes through.
+				s.logger.Warning(ctx, "failed to send sign in email", err)
+			}
+			s.logger.Info(ctx, "Sent a sign in email")
+		} else {
+			s.logger.Info(ctx, "Skipped sign in email based on notification preferences")
 		}
-		s.logger.Info(ctx, "Sent a sign in email")
 
 		// Session is both valid and not locked. This is a trusted session. We should add the IP
 		// to the list of trust IPs. At a minimum, the session they signed up with will have its
 		// IP address stored as trusted.
 		s.logger.Info(ctx, "Session is valid and trusted, adding to list of trusted IPs")
-		err = s.userRepository.UpdateOrAddTrustedIPToUser(user.Email, IP{Address: currentIP, LocationFound: false})
+		err = s.userRepository.UpdateOrAddTrustedIPToUser(user.Email, ipDetails)
 		if err != nil {
 			s.logger.Warning(ctx, "failed to save new trusted IP", err)
 		}
 	}
 
-	return token, lockSession, nil
+	return SignInResult{
+		Token:                 token,
+		SessionLocked:         lockSession,
+		PasswordResetRequired: passwordResetRequired,
+	}, nil
 }
 
 func (s *Services) isUsersPassword(storedPasswordHash string, plainTextInputtedPassword string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(storedPasswordHash), []byte(plainTextInputtedPassword)) == nil
 }
 
+// isPasswordReused checks the plain text password against the current password and the password history.
+func (s *Services) isPasswordReused(user User, plainTextInputtedPassword string) bool {
+	if s.isUsersPassword(user.Password, plainTextInputtedPassword) {
+		return true
+	}
+	for _, previousHash := range user.PasswordHistory {
+		if previousHash == user.Password {
+			// Already checked above, bcrypt is slow so skip it
+			continue
+		}
+		if s.isUsersPassword(previousHash, plainTextInputtedPassword) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Services) validateSignIn(ctx context.Context, isSignUp bool, user User, userAgent *user_agent.UserAgent, currentIP string) (bool, bool, error) {
 	ctx = context.WithValue(ctx, logging.CtxHelpMethods, logging.AddToHelperMethods(ctx, "validateSignIn"))
 
@@ -369,12 +413,43 @@ func (s *Services) validateSignIn(ctx context.Context, isSignUp bool, user User,
 	return false, false, nil
 }
 
-func (s *Services) lockUserAccount(user User) error {
-	err := accountlockemail.SendAccountLockedEmail("there", user.Email)
+func (s *Services) lockUserAccount(ctx context.Context, user User, isNewDevice bool) error {
+	if ShouldNotify(user.NotificationPreferences.AccountLocked, isNewDevice) {
+		err := accountlockemail.SendAccountLockedEmail(user.Greeting(), user.Email)
+		if err != nil {
+			return err
+		}
+	} else {
+		s.logger.Info(ctx, "Skipped account locked email based on notification preferences")
+	}
+	err := s.userRepository.UpdateAccountLocked(user.Email, true)
 	if err != nil {
 		return err
 	}
-	return s.userRepository.UpdateAccountLocked(user.Email, true)
+	s.auditServices.Record(ctx, audit.Event{Type: audit.AccountLocked, Email: user.Email, Actor: "system"})
+	return nil
+}
+
+// getDevice builds a device from the User Agent header. The header is optional so the device can be empty.
+func (s *Services) getDevice(name string, userAgent *user_agent.UserAgent) Device {
+	if userAgent == nil {
+		return Device{Name: name, ValidDevice: true}
+	}
+	engine, engineVersion := userAgent.Engine()
+	browserName, browserVersion := userAgent.Browser()
+	return Device{
+		Name: name,
+		Mobile: userAgent.Mobile(),
+		Bot: userAgent.Bot(),
+		Mozilla: userAgent.Mozilla(),
+		Platform: userAgent.Platform(),
+		OperatingSystem: userAgent.OS(),
+		Engine: engine,
+		EngineVersion: engineVersion,
+		Browser: browserName,
+		BrowserVersion: browserVersion,
+		ValidDevice: true,
+	}
 }
 
 func (s *Services) LogOut(ctx context.Context, authToken string) *common.Error {
@@ -441,6 +516,8 @@ func (s *Services) UnlockSession(ctx context.Context, currentIP string, authToke
 			}
 		}
 
+		s.auditServices.Record(ctx, audit.Event{Type: audit.SessionUnlocked, Email: session.Email})
+
 		return nil
 	}
 	return &common.Error{
@@ -481,7 +558,7 @@ func (s *Services) SendForgotPassword(ctx context.Context, currentIP string, bod
 	// Create instance
 	code := uuid.New().String()
 	now := time.Now()
-	expiry := now.AddDate(0, 0, 1)
+	expiry := now.AddDate(0, 0, 1) // Expires in 1 day
 	err = s.forgotPasswordRepository.Save(ForgotPasswordCode{
 		Email: body.GetFormattedEmail(),
 		Code: code,
@@ -507,7 +584,7 @@ func (s *Services) SendForgotPassword(ctx context.Context, currentIP string, bod
 	return nil
 }
 
-func (s *Services) ForgotPassword(ctx context.Context, currentIP string, body ResetForgotPasswordBody) *common.Error {
+func (s *Services) ForgotPassword(ctx context.Context, userAgent *user_agent.UserAgent, currentIP string, body ResetForgotPasswordBody) *common.Error {
 	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "ForgotPassword")
 
 	// Validate all fields
@@ -552,7 +629,28 @@ func (s *Services) ForgotPassword(ctx context.Context, currentIP string, body Re
 		}
 	}
 
-	// Update password
+	// Stop the user from resetting to a password that was just used (possibly the compromised one)
+	found, user, err := s.userRepository.GetUserByEmail(body.GetFormattedEmail())
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get user by email", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found {
+		s.logger.Warning(ctx, "failed to find user", errors.New("not found"))
+		return &common.Error{
+			StatusCode: 403,
+		}
+	}
+	if s.isPasswordReused(user, body.NewPassword) {
+		s.logger.Warning(ctx, "password matches a recent password", errors.New("invalid password"))
+		return &common.Error{
+			StatusCode: 400,
+			Message: "Password has been used recently",
+		}
+	}
+
 	hash, err := s.getEncryptedPassword(body.NewPassword)
 	if err != nil {
 		s.logger.Warning(ctx, "failed to hash password", err)
@@ -560,7 +658,25 @@ func (s *Services) ForgotPassword(ctx context.Context, currentIP string, body Re
 			StatusCode: 500,
 		}
 	}
-	err = s.userRepository.UpdatePassword(body.GetFormattedEmail(), hash)
+
+	// Use up the code before touching the password. Two requests with the same code can both pass the exists
+	// check above, only one of them can complete it.
+	completed, err := s.forgotPasswordRepository.MarkCodeAsComplete(body.GetFormattedEmail(), body.Code)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to mark code as completed", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !completed {
+		s.logger.Warning(ctx, "forgot password code was already used or expired", errors.New("unauthorized"))
+		return &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	// Update password
+	err = s.userRepository.UpdatePassword(body.GetFormattedEmail(), hash, GetPasswordHistoryLimit())
 	if err != nil {
 		s.logger.Warning(ctx, "failed to update password", err)
 		return &common.Error{
@@ -568,12 +684,178 @@ func (s *Services) ForgotPassword(ctx context.Context, currentIP string, body Re
 		}
 	}
 
-	// Update forgot password code
-	err = s.forgotPasswordRepository.MarkCodeAsComplete(body.GetFormattedEmail(), body.Code)
+	s.auditServices.Record(ctx, audit.Event{Type: audit.PasswordReset, Email: user.Email})
+
+	// Any other codes that were sent out are no longer needed
+	err = s.forgotPasswordRepository.ExpireAllCodes(user.Email)
 	if err != nil {
-		s.logger.Error(ctx, "failed to update mark code as completed", err)
+		s.logger.Error(ctx, "failed to expire outstanding forgot password codes", err)
 		// Not returning error for UX
 	}
 
+	// Whoever had access with the old password should not keep it
+	err = s.userRepository.ExpireAllSessions(user.Email)
+	if err != nil {
+		s.logger.Error(ctx, "failed to expire sessions", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	// Resetting the password is how a user gets back into a locked account
+	if user.AccountLocked {
+		err = s.userRepository.UpdateAccountLocked(user.Email, false)
+		if err != nil {
+			s.logger.Error(ctx, "failed to unlock account", err)
+			return &common.Error{
+				StatusCode: 500,
+			}
+		}
+	}
+
+	isNewDevice := !user.HasKnownDevice(s.getDevice("", userAgent))
+	if ShouldNotify(user.NotificationPreferences.PasswordChanged, isNewDevice) {
+		err = passwordchangedemail.SendPasswordChangedEmail(user.Greeting(), user.Email)
+		if err != nil {
+			// Ignore the failure, the password has already been changed.
+			s.logger.Warning(ctx, "failed to send password changed email", err)
+		}
+	} else {
+		s.logger.Info(ctx, "Skipped password changed email based on notification preferences")
+	}
+
 	return nil
-}
\ No newline at end of file
+}
+
+// ChangePassword updates the password for the signed in user. This is also the only action allowed when the
+// session was created with an expired password.
+func (s *Services) ChangePassword(ctx context.Context, session Session, body ChangePasswordBody) *common.Error {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "ChangePassword")
+
+	found, user, err := s.userRepository.GetUserByEmail(session.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get user by email", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found {
+		s.logger.Warning(ctx, "failed to find user", errors.New("not found"))
+		return &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	if !s.isUsersPassword(user.Password, body.CurrentPassword) {
+		s.logger.Warning(ctx, "invalid current password", errors.New("unauthorized"))
+		return &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	// Validate password strength
+	if !s.isValidPassword(ctx, body.NewPassword) {
+		return &common.Error{
+			StatusCode: 400,
+			Message: "Password does not meet requirements",
+		}
+	}
+
+	if s.isPasswordReused(user, body.NewPassword) {
+		s.logger.Warning(ctx, "password matches a recent password", errors.New("invalid password"))
+		return &common.Error{
+			StatusCode: 400,
+			Message: "Password has been used recently",
+		}
+	}
+
+	hash, err := s.getEncryptedPassword(body.NewPassword)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to hash password", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+	err = s.userRepository.UpdatePassword(user.Email, hash, GetPasswordHistoryLimit())
+	if err != nil {
+		s.logger.Warning(ctx, "failed to update password", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	s.auditServices.Record(ctx, audit.Event{Type: audit.PasswordChanged, Email: user.Email})
+
+	// Password is fresh again so any sessions waiting on a change can be used normally
+	err = s.userRepository.ClearPasswordResetRequired(user.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to clear password reset required on sessions", err)
+		return &common.Error{
+			StatusCode: 500,
+		}
+	}
+
+	return nil
+}
+
+func (s *Services) GetNotificationPreferences(ctx context.Context, session Session) (NotificationPreferences, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetNotificationPreferences")
+
+	found, user, err := s.userRepository.GetUserByEmail(session.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get user by email", err)
+		return NotificationPreferences{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found {
+		s.logger.Warning(ctx, "failed to find user", errors.New("not found"))
+		return NotificationPreferences{}, &common.Error{
+			StatusCode: 403,
+		}
+	}
+	return user.NotificationPreferences.WithDefaults(), nil
+}
+
+func (s *Services) UpdateNotificationPreferences(ctx context.Context, session Session, body UpdateNotificationPreferencesBody) (NotificationPreferences, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "UpdateNotificationPreferences")
+
+	found, user, err := s.userRepository.GetUserByEmail(session.Email)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to get user by email", err)
+		return NotificationPreferences{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	if !found {
+		s.logger.Warning(ctx, "failed to find user", errors.New("not found"))
+		return NotificationPreferences{}, &common.Error{
+			StatusCode: 403,
+		}
+	}
+
+	preferences := body.Apply(user.NotificationPreferences.WithDefaults())
+	err = s.userRepository.UpdateNotificationPreferences(user.Email, preferences)
+	if err != nil {
+		s.logger.Warning(ctx, "failed to update notification preferences", err)
+		return NotificationPreferences{}, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	return preferences, nil
+}
+
+// GetActivity lists the audit events for the signed in user's account.
+func (s *Services) GetActivity(ctx context.Context, session Session, query audit.ListEventsQuery) ([]audit.Event, int64, *common.Error) {
+	ctx = context.WithValue(ctx, logging.CtxServiceMethod, "GetActivity")
+
+	// Users can only ever see their own account
+	query.Email = session.Email
+	events, total, err := s.auditServices.List(ctx, query)
+	if err != nil {
+		return []audit.Event{}, 0, &common.Error{
+			StatusCode: 500,
+		}
+	}
+	return events, total, nil
+}